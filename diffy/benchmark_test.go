@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// benchmarkSize reads GOPHX_BENCH_RESOURCES, defaulting to 200 resources.
+func benchmarkSize() int {
+	if n, err := strconv.Atoi(os.Getenv("GOPHX_BENCH_RESOURCES")); err == nil && n > 0 {
+		return n
+	}
+	return 200
+}
+
+// syntheticSchema builds a provider schema with the given number of resource
+// types, each with attributes and two levels of nested blocks.
+func syntheticSchema(types int) *TerraformSchema {
+	resources := make(map[string]*ResourceSchema, types)
+	for i := 0; i < types; i++ {
+		leaf := &SchemaBlock{Attributes: map[string]*SchemaAttribute{}}
+		for a := 0; a < 10; a++ {
+			leaf.Attributes[fmt.Sprintf("leaf_%d", a)] = &SchemaAttribute{Optional: true}
+		}
+		nested := &SchemaBlock{
+			Attributes: map[string]*SchemaAttribute{"name": {Required: true}},
+			BlockTypes: map[string]*SchemaBlockType{"leaf": {Nesting: "list", Block: leaf}},
+		}
+		root := &SchemaBlock{
+			Attributes: map[string]*SchemaAttribute{"id": {Computed: true}},
+			BlockTypes: map[string]*SchemaBlockType{
+				"nested":   {Nesting: "list", MinItems: 1, Block: nested},
+				"optional": {Nesting: "list", Block: nested},
+			},
+		}
+		for a := 0; a < 40; a++ {
+			root.Attributes[fmt.Sprintf("attr_%d", a)] = &SchemaAttribute{Required: a < 5, Optional: a >= 5}
+		}
+		resources[fmt.Sprintf("synthetic_type_%d", i)] = &ResourceSchema{Block: root}
+	}
+	return &TerraformSchema{ProviderSchemas: map[string]*ProviderSchema{
+		"registry.terraform.io/hashicorp/synthetic": {ResourceSchemas: resources},
+	}}
+}
+
+// syntheticModule renders n resources cycling over the synthetic types, half
+// of them declaring their nested block dynamically several times over.
+func syntheticModule(n, types int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "resource \"synthetic_type_%d\" \"r%d\" {\n", i%types, i)
+		for a := 0; a < 20; a++ {
+			fmt.Fprintf(&b, "  attr_%d = \"value\"\n", a)
+		}
+		if i%2 == 0 {
+			b.WriteString("  nested {\n    name = \"static\"\n    leaf {\n      leaf_0 = 1\n    }\n  }\n")
+		} else {
+			for d := 0; d < 5; d++ {
+				fmt.Fprintf(&b, "  dynamic \"nested\" {\n    for_each = var.items\n    content {\n      name = \"dyn\"\n      leaf_%d = 1\n    }\n  }\n", d)
+			}
+		}
+		b.WriteString("}\n\n")
+	}
+	return b.String()
+}
+
+func BenchmarkParseAndValidate(b *testing.B) {
+	const types = 25
+	n := benchmarkSize()
+	src := syntheticModule(n, types)
+	schema := syntheticSchema(types)
+	providers := map[string]ProviderConfig{"synthetic": {Source: "registry.terraform.io/hashicorp/synthetic"}}
+	parser := &DefaultHCLParser{}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resources, err := parser.ParseMainString(src, "main.tf")
+		if err != nil {
+			b.Fatal(err)
+		}
+		result := ValidateModule(&Module{Providers: providers, Resources: resources}, schema, Options{})
+		if result.Validated != n {
+			b.Fatalf("validated %d of %d resources", result.Validated, n)
+		}
+	}
+}
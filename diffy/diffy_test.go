@@ -3,19 +3,39 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math/big"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclparse"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type TerraformSchema struct {
@@ -23,7 +43,9 @@ type TerraformSchema struct {
 }
 
 type ProviderSchema struct {
-	ResourceSchemas map[string]*ResourceSchema `json:"resource_schemas"`
+	Provider          *ResourceSchema            `json:"provider"`
+	ResourceSchemas   map[string]*ResourceSchema `json:"resource_schemas"`
+	DataSourceSchemas map[string]*ResourceSchema `json:"data_source_schemas"`
 }
 
 type ResourceSchema struct {
@@ -33,12 +55,17 @@ type ResourceSchema struct {
 type SchemaBlock struct {
 	Attributes map[string]*SchemaAttribute `json:"attributes"`
 	BlockTypes map[string]*SchemaBlockType `json:"block_types"`
+	// Deprecated is where terraform providers schema -json marks a
+	// deprecated nested block.
+	Deprecated bool `json:"deprecated"`
 }
 
 type SchemaAttribute struct {
-	Required bool `json:"required"`
-	Optional bool `json:"optional"`
-	Computed bool `json:"computed"`
+	Required   bool `json:"required"`
+	Optional   bool `json:"optional"`
+	Computed   bool `json:"computed"`
+	Deprecated bool `json:"deprecated"`
+	Sensitive  bool `json:"sensitive"`
 }
 
 type SchemaBlockType struct {
@@ -46,33 +73,175 @@ type SchemaBlockType struct {
 	MinItems int          `json:"min_items"`
 	MaxItems int          `json:"max_items"`
 	Block    *SchemaBlock `json:"block"`
+	// Deprecated marks the block type itself; see also Block.Deprecated.
+	Deprecated bool `json:"deprecated"`
 }
 
+func (bt *SchemaBlockType) deprecated() bool {
+	return bt.Deprecated || bt.Block != nil && bt.Block.Deprecated
+}
+
+// computedOnly reports whether b has no nested blocks and every attribute is
+// computed without being optional, so a configuration cannot set anything.
+func (b *SchemaBlock) computedOnly() bool {
+	if len(b.BlockTypes) > 0 {
+		return false
+	}
+	for _, attr := range b.Attributes {
+		if attr.Required || attr.Optional || !attr.Computed {
+			return false
+		}
+	}
+	return true
+}
+
+// Logger receives progress and finding messages; *testing.T satisfies it.
+type Logger interface {
+	Logf(format string, args ...any)
+}
+
+type discardLogger struct{}
+
+func (discardLogger) Logf(string, ...any) {}
+
 type BlockProcessor interface {
 	ParseAttributes(body *hclsyntax.Body)
 	ParseBlocks(body *hclsyntax.Body)
-	Validate(t *testing.T, resourceType, path string, schema *SchemaBlock, parentIgnore []string, findings *[]ValidationFinding)
+	Validate(log Logger, resourceType, path string, schema *SchemaBlock, parentIgnore []string, opts *Options, findings *[]ValidationFinding)
 }
 
 type IssueManager interface {
 	CreateOrUpdateIssue(findings []ValidationFinding) error
 }
 
+// FindingFormatter renders findings for a sink such as an issue body or a
+// report file.
+type FindingFormatter interface {
+	Format(findings []ValidationFinding) (string, error)
+}
+
 type HCLParser interface {
 	ParseProviderRequirements(filename string) (map[string]ProviderConfig, error)
 	ParseMainFile(filename string) ([]ParsedResource, error)
+	ParseModuleFiles(dir string) ([]ParsedResource, error)
+	ListResourceTypes(root string) ([]string, error)
+	ParseTfvars(filename string) (map[string]cty.Value, error)
+	ParseDeclaredAddresses(filename string) (map[string]bool, error)
+	ParseProviderBlocks(filename string) ([]ParsedProvider, error)
+	ParseOutputs(filename string) ([]ParsedOutput, error)
+	ParseCloudBlock(filename string) (*ParsedBlock, error)
+	ParseMovedBlocks(filename string) ([]ParsedMoved, error)
+	ParseModuleCalls(filename string) ([]ParsedModuleCall, error)
+	ParseVariables(filename string) ([]string, error)
+	ParseVariableReferences(filename string) (map[string]bool, error)
+	ParseLocals(filename string) (map[string]hclsyntax.Expression, error)
+	ParseRequiredVersion(filename string) (string, error)
 }
 
 type RepositoryInfoProvider interface {
 	GetRepoInfo() (owner, name string)
 }
 
+type FindingKind string
+
+const (
+	FindingMissing FindingKind = "missing"
+	FindingInvalid FindingKind = "invalid"
+	// FindingInfo marks lint-style findings that are worth a review but
+	// are not errors.
+	FindingInfo FindingKind = "info"
+	// FindingUnexpected marks attributes set in HCL that the schema does not
+	// define.
+	FindingUnexpected FindingKind = "unexpected"
+)
+
 type ValidationFinding struct {
 	ResourceType string
 	Path         string
 	Name         string
 	Required     bool
 	IsBlock      bool
+	Kind         FindingKind
+	Message      string
+}
+
+// Options holds the policy configuration loaded from the GOPHX_CONFIG file.
+type Options struct {
+	// Logger receives validation messages; nil discards them.
+	Logger Logger `json:"-"`
+	// SchemaFile points at a cached provider schema, skipping terraform init.
+	SchemaFile string `json:"schema_file"`
+	// AllowedValues maps resource type, or provider.<name> for provider
+	// blocks, to attribute name to the permitted literal values.
+	AllowedValues map[string]map[string][]string `json:"allowed_values"`
+	// RequireTags reports a missing optional tags attribute as required.
+	RequireTags bool `json:"require_tags"`
+	// RequiredTagKeys lists keys that every resource's tags must set. Tags
+	// built with merge() of literal maps and locals are resolved first.
+	RequiredTagKeys []string `json:"required_tag_keys"`
+	// RequiredAttributes lists, per resource type, optional attributes that
+	// are reported as required when missing.
+	RequiredAttributes map[string][]string `json:"required_attributes"`
+	// RequiredBlocks lists, per resource type, optional top-level blocks that
+	// are reported as required when missing.
+	RequiredBlocks map[string][]string `json:"required_blocks"`
+	// CIDRAttributes names attributes whose literal values must be valid CIDRs.
+	CIDRAttributes []string `json:"cidr_attributes"`
+	// MaxDependsOn, when positive, reports resources whose depends_on lists
+	// more entries, as heavy explicit dependencies often hide missing
+	// references.
+	MaxDependsOn int `json:"max_depends_on"`
+	// VariableAttributes names attributes, such as location or region, that
+	// must reference a variable or other expression instead of a literal.
+	VariableAttributes []string `json:"variable_attributes"`
+	// CredentialAttributes maps provider local names to the attributes that
+	// must not be set to literals in provider blocks. Providers not listed
+	// use defaultCredentialAttributes.
+	CredentialAttributes map[string][]string `json:"credential_attributes"`
+	// NumericRanges maps resource type to attribute name to the range its
+	// literal numeric value must fall in.
+	NumericRanges map[string]map[string]NumericRange `json:"numeric_ranges"`
+	// OnlyTypes, when non-empty, limits validation to these resource types.
+	OnlyTypes []string `json:"only_types"`
+	// SkipTypes excludes resource types from validation and wins over OnlyTypes.
+	SkipTypes []string `json:"skip_types"`
+	// SensitivePattern matches output names and referenced attribute names
+	// that expose secrets. Defaults to names ending in a password, secret or
+	// key segment.
+	SensitivePattern *regexp.Regexp `json:"sensitive_pattern"`
+	// NoPreventDestroyTypes lists resource types that are meant to be
+	// recreated and must not set lifecycle prevent_destroy.
+	NoPreventDestroyTypes []string `json:"no_prevent_destroy_types"`
+	// NamePattern, when set, must match every resource block name.
+	NamePattern *regexp.Regexp `json:"name_pattern"`
+	// CheckDeprecated reports set attributes and declared blocks the schema
+	// marks deprecated.
+	CheckDeprecated bool `json:"check_deprecated"`
+	// DeprecatedAllowlist lists type:attr or type:block pairs exempt from
+	// deprecation findings.
+	DeprecatedAllowlist []string `json:"deprecated_allowlist"`
+	// RequireComments reports resource blocks without a leading comment.
+	RequireComments bool `json:"require_comments"`
+	// ProviderVersionRanges maps provider local names to the version range,
+	// e.g. ">= 3.0, < 5.0", their required_providers constraint must stay in.
+	ProviderVersionRanges map[string]string `json:"provider_version_ranges"`
+	// RequireIndexedNames reports count and for_each resources whose name
+	// and tags never reference count.index or each.key/each.value.
+	RequireIndexedNames bool `json:"require_indexed_names"`
+	// RequireProviderVersions reports required_providers entries without a
+	// version constraint.
+	RequireProviderVersions bool `json:"require_provider_versions"`
+	// CheckUnknownAttributes reports resource attributes the schema does not
+	// define, which usually means a typo or a removed argument.
+	CheckUnknownAttributes bool `json:"check_unknown_attributes"`
+	// FmtCheck reports .tf files that terraform fmt would rewrite.
+	FmtCheck bool `json:"fmt_check"`
+	// Validators are custom checks run after the built-in ones on every
+	// resource with a schema; add them with RegisterValidator.
+	Validators []ResourceValidator `json:"-"`
+	// RequiredOnly keeps only required findings. Optional findings, such as
+	// missing optional items or deprecated attributes, are not even logged.
+	RequiredOnly bool `json:"required_only"`
 }
 
 type ProviderConfig struct {
@@ -80,17 +249,89 @@ type ProviderConfig struct {
 	Version string
 }
 
+// ResourceKind tells managed resources and data sources apart.
+type ResourceKind string
+
+const (
+	KindResource ResourceKind = "resource"
+	KindData     ResourceKind = "data"
+)
+
 type ParsedResource struct {
+	Kind ResourceKind
 	Type string
 	Name string
 	data BlockData
+	rng  hcl.Range
+}
+
+// Label is the type used in findings: the resource type, or data.<type> for
+// a data source.
+func (r ParsedResource) Label() string {
+	if r.Kind == KindData {
+		return "data." + r.Type
+	}
+	return r.Type
+}
+
+// ParsedProvider is a provider configuration block. The alias meta-argument
+// is kept apart from the schema attributes.
+type ParsedProvider struct {
+	Name  string
+	Alias string
+	data  BlockData
+}
+
+// Address is the provider reference used by resources, e.g. "azurerm.secondary".
+func (p ParsedProvider) Address() string {
+	if p.Alias == "" {
+		return p.Name
+	}
+	return p.Name + "." + p.Alias
+}
+
+// ParsedModuleCall is a module block. Module is the parsed child for a local
+// source and nil for a remote one or a local path without .tf files.
+type ParsedModuleCall struct {
+	Name   string
+	Source string
+	Module *Module
+}
+
+// Local reports whether the source is a path relative to the calling module.
+func (c ParsedModuleCall) Local() bool {
+	return strings.HasPrefix(c.Source, "./") || strings.HasPrefix(c.Source, "../")
+}
+
+// ParsedMoved is a moved block with its from and to addresses.
+type ParsedMoved struct {
+	From string
+	To   string
+}
+
+type ParsedOutput struct {
+	Name      string
+	Sensitive bool
+	Value     hclsyntax.Expression
 }
 
 type BlockData struct {
 	properties    map[string]bool
+	attributes    map[string]*hclsyntax.Attribute
 	staticBlocks  map[string]*ParsedBlock
 	dynamicBlocks map[string]*ParsedBlock
+	// blockCounts holds how many static blocks of each type the body declares.
+	blockCounts   map[string]int
 	ignoreChanges []string
+	// preventDestroy records lifecycle { prevent_destroy = true }.
+	preventDestroy bool
+	// missingContent lists dynamic block labels declared without a content block.
+	missingContent []string
+	// forEach holds the for_each expressions of dynamic blocks by label.
+	forEach map[string][]hclsyntax.Expression
+	// staleIterators maps dynamic block labels whose content uses the label
+	// as iterator to the custom iterator name declared instead.
+	staleIterators map[string]string
 }
 
 type ParsedBlock struct {
@@ -100,16 +341,21 @@ type ParsedBlock struct {
 // Implement BlockProcessor for BlockData
 func NewBlockData() BlockData {
 	return BlockData{
-		properties:    make(map[string]bool),
-		staticBlocks:  make(map[string]*ParsedBlock),
-		dynamicBlocks: make(map[string]*ParsedBlock),
-		ignoreChanges: []string{},
+		properties:     make(map[string]bool),
+		attributes:     make(map[string]*hclsyntax.Attribute),
+		staticBlocks:   make(map[string]*ParsedBlock),
+		dynamicBlocks:  make(map[string]*ParsedBlock),
+		blockCounts:    make(map[string]int),
+		ignoreChanges:  []string{},
+		forEach:        make(map[string][]hclsyntax.Expression),
+		staleIterators: make(map[string]string),
 	}
 }
 
 func (bd *BlockData) ParseAttributes(body *hclsyntax.Body) {
-	for name := range body.Attributes {
+	for name, attr := range body.Attributes {
 		bd.properties[name] = true
+		bd.attributes[name] = attr
 	}
 }
 
@@ -124,34 +370,74 @@ func (bd *BlockData) ParseBlocks(body *hclsyntax.Body) {
 			}
 		default:
 			parsed := ParseSyntaxBody(block.Body)
+			if prev := bd.staticBlocks[block.Type]; prev != nil {
+				maxBlockCounts(&parsed.data, &prev.data)
+			}
 			bd.staticBlocks[block.Type] = parsed
+			bd.blockCounts[block.Type]++
 		}
 	}
 }
 
-func (bd *BlockData) Validate(t *testing.T, resourceType, path string, schema *SchemaBlock, parentIgnore []string, findings *[]ValidationFinding) {
+func (bd *BlockData) Validate(log Logger, resourceType, path string, schema *SchemaBlock, parentIgnore []string, opts *Options, findings *[]ValidationFinding) {
 	if schema == nil {
 		return
 	}
 
 	ignore := append(parentIgnore, bd.ignoreChanges...)
-	bd.validateAttributes(t, resourceType, path, schema, ignore, findings)
-	bd.validateBlocks(t, resourceType, path, schema, ignore, findings)
+	if !opts.requiredOnly() {
+		bd.validateDynamicContent(log, resourceType, path, findings)
+	}
+	bd.validateAttributes(log, resourceType, path, schema, ignore, opts, findings)
+	bd.validateBlocks(log, resourceType, path, schema, ignore, opts, findings)
+}
+
+// walk visits bd and every nested static and dynamic block in name order.
+func (bd *BlockData) walk(path string, fn func(path string, bd *BlockData)) {
+	fn(path, bd)
+	for _, blocks := range []map[string]*ParsedBlock{bd.staticBlocks, bd.dynamicBlocks} {
+		names := make([]string, 0, len(blocks))
+		for name := range blocks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			blocks[name].data.walk(path+"."+name, fn)
+		}
+	}
 }
 
 // Original helper methods
 func (bd *BlockData) parseLifecycle(body *hclsyntax.Body) {
 	for name, attr := range body.Attributes {
-		if name == "ignore_changes" {
+		switch name {
+		case "ignore_changes":
 			val, _ := attr.Expr.Value(nil)
 			bd.ignoreChanges = extractIgnoreChanges(val)
+		case "prevent_destroy":
+			if val, ok := literalValue(attr.Expr, nil); ok && val.Type() == cty.Bool {
+				bd.preventDestroy = val.True()
+			}
 		}
 	}
 }
 
 func (bd *BlockData) parseDynamicBlock(body *hclsyntax.Body, name string) {
-	contentBlock := findContentBlock(body)
+	if attr, ok := body.Attributes["for_each"]; ok {
+		bd.forEach[name] = append(bd.forEach[name], attr.Expr)
+	}
+
+	contentBlock, ok := findContentBlock(body)
+	if !ok {
+		if !contains(bd.missingContent, name) {
+			bd.missingContent = append(bd.missingContent, name)
+		}
+		return
+	}
 	parsed := ParseSyntaxBody(contentBlock)
+	if iterator := dynamicIterator(body, name); iterator != name && referencesRoot(contentBlock, name) {
+		bd.staleIterators[name] = iterator
+	}
 
 	if existing := bd.dynamicBlocks[name]; existing != nil {
 		mergeBlocks(existing, parsed)
@@ -160,51 +446,337 @@ func (bd *BlockData) parseDynamicBlock(body *hclsyntax.Body, name string) {
 	}
 }
 
-func (bd *BlockData) validateAttributes(t *testing.T, resType, path string, schema *SchemaBlock, ignore []string, findings *[]ValidationFinding) {
+// dynamicIterator returns the iterator variable of a dynamic block body,
+// which defaults to the block label.
+func dynamicIterator(body *hclsyntax.Body, label string) string {
+	if attr, ok := body.Attributes["iterator"]; ok {
+		if traversal, diags := hcl.AbsTraversalForExpr(attr.Expr); !diags.HasErrors() {
+			return traversal.RootName()
+		}
+	}
+	return label
+}
+
+// referencesRoot reports whether any expression in body refers to name.
+func referencesRoot(body *hclsyntax.Body, name string) bool {
+	found := false
+	hclsyntax.VisitAll(body, func(node hclsyntax.Node) hcl.Diagnostics {
+		if attr, ok := node.(*hclsyntax.Attribute); ok {
+			for _, traversal := range attr.Expr.Variables() {
+				if traversal.RootName() == name {
+					found = true
+				}
+			}
+		}
+		return nil
+	})
+	return found
+}
+
+func (bd *BlockData) validateDynamicContent(log Logger, resType, path string, findings *[]ValidationFinding) {
+	labels := make([]string, 0, len(bd.staleIterators))
+	for name := range bd.staleIterators {
+		labels = append(labels, name)
+	}
+	sort.Strings(labels)
+	for _, name := range labels {
+		msg := fmt.Sprintf("content references %s but the iterator is %s", name, bd.staleIterators[name])
+		*findings = append(*findings, ValidationFinding{
+			ResourceType: resType,
+			Path:         path,
+			Name:         name,
+			IsBlock:      true,
+			Kind:         FindingInvalid,
+			Message:      msg,
+		})
+		log.Logf("%s dynamic block %s in %s: %s", resType, name, strings.ReplaceAll(path, "root.", ""), msg)
+	}
+
+	for _, name := range bd.missingContent {
+		*findings = append(*findings, ValidationFinding{
+			ResourceType: resType,
+			Path:         path,
+			Name:         name,
+			IsBlock:      true,
+			Kind:         FindingInvalid,
+			Message:      "dynamic block missing content",
+		})
+		log.Logf("%s dynamic block %s missing content in %s", resType, name, strings.ReplaceAll(path, "root.", ""))
+	}
+}
+
+// dynamicAlwaysEmpty reports whether every dynamic block labelled name
+// iterates over an empty literal collection.
+func (bd *BlockData) dynamicAlwaysEmpty(name string) bool {
+	exprs := bd.forEach[name]
+	if len(exprs) == 0 {
+		return false
+	}
+	for _, expr := range exprs {
+		val, ok := literalValue(expr, nil)
+		if !ok || !val.CanIterateElements() || val.LengthInt() != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// dynamicInstances returns how many blocks the dynamic blocks labelled name
+// produce, when every for_each is a collection of known length.
+func (bd *BlockData) dynamicInstances(name string) (int, bool) {
+	exprs := bd.forEach[name]
+	if len(exprs) == 0 {
+		return 0, false
+	}
+	total := 0
+	for _, expr := range exprs {
+		switch e := expr.(type) {
+		case *hclsyntax.TupleConsExpr:
+			total += len(e.Exprs)
+		case *hclsyntax.ObjectConsExpr:
+			total += len(e.Items)
+		default:
+			val, ok := literalValue(expr, nil)
+			if !ok || !val.CanIterateElements() {
+				return 0, false
+			}
+			total += val.LengthInt()
+		}
+	}
+	return total, true
+}
+
+// itemBounds checks how many name blocks bd declares against the schema's
+// item bounds. Dynamic blocks count only when their for_each has a known
+// length; otherwise the static count is a lower bound and only max_items is
+// checked.
+func (bd *BlockData) itemBounds(name string, blockType *SchemaBlockType) string {
+	n := bd.blockCounts[name]
+	exact := true
+	if len(bd.forEach[name]) > 0 {
+		if instances, ok := bd.dynamicInstances(name); ok {
+			n += instances
+		} else {
+			exact = false
+		}
+	}
+
+	subject := fmt.Sprintf("%d blocks", n)
+	if bd.blockCounts[name] == 0 {
+		subject = fmt.Sprintf("dynamic block for_each yields %d blocks", n)
+	}
+	switch {
+	case blockType.MaxItems > 0 && n > blockType.MaxItems:
+		return fmt.Sprintf("%s, exceeds max_items %d", subject, blockType.MaxItems)
+	case exact && n > 0 && n < blockType.MinItems:
+		return fmt.Sprintf("%s, below min_items %d", subject, blockType.MinItems)
+	}
+	return ""
+}
+
+func (bd *BlockData) validateAttributes(log Logger, resType, path string, schema *SchemaBlock, ignore []string, opts *Options, findings *[]ValidationFinding) {
 	for name, attr := range schema.Attributes {
+		if attr.Computed && !attr.Optional && bd.properties[name] && !opts.requiredOnly() {
+			*findings = append(*findings, ValidationFinding{
+				ResourceType: resType,
+				Path:         path,
+				Name:         name,
+				Kind:         FindingInvalid,
+				Message:      "cannot set computed attribute",
+			})
+			log.Logf("%s cannot set computed attribute %s in %s", resType, name, strings.ReplaceAll(path, "root.", ""))
+			continue
+		}
+		if attr.Deprecated && bd.properties[name] && opts.reportsDeprecated(resType, name) && !opts.requiredOnly() {
+			*findings = append(*findings, ValidationFinding{
+				ResourceType: resType,
+				Path:         path,
+				Name:         name,
+				Kind:         FindingInvalid,
+				Message:      "attribute is deprecated",
+			})
+			log.Logf("%s uses deprecated attribute %s in %s", resType, name, strings.ReplaceAll(path, "root.", ""))
+			continue
+		}
 		if attr.Computed || contains(ignore, name) {
 			continue
 		}
 		if !bd.properties[name] {
+			required := attr.Required || opts.requiresAttribute(resType, path, name)
+			if !required && opts.requiredOnly() {
+				continue
+			}
 			*findings = append(*findings, ValidationFinding{
 				ResourceType: resType,
 				Path:         path,
 				Name:         name,
-				Required:     attr.Required,
+				Required:     required,
 				IsBlock:      false,
+				Kind:         FindingMissing,
 			})
-			logMissingAttribute(t, resType, name, path, attr.Required)
+			logMissingAttribute(log, resType, name, path, required)
 		}
 	}
 }
 
-func (bd *BlockData) validateBlocks(t *testing.T, resType, path string, schema *SchemaBlock, ignore []string, findings *[]ValidationFinding) {
+func (bd *BlockData) validateBlocks(log Logger, resType, path string, schema *SchemaBlock, ignore []string, opts *Options, findings *[]ValidationFinding) {
+	if !opts.requiredOnly() {
+		bd.validateDynamicLabels(log, resType, path, schema, findings)
+	}
+
 	for name, blockType := range schema.BlockTypes {
-		if name == "timeouts" || contains(ignore, name) {
+		if name == "timeouts" || contains(ignore, name) || contains(bd.missingContent, name) {
 			continue
 		}
 
 		static := bd.staticBlocks[name]
 		dynamic := bd.dynamicBlocks[name]
 		if static == nil && dynamic == nil {
+			required := blockType.MinItems > 0 || opts.requiresBlock(resType, path, name)
+			if !required && opts.requiredOnly() {
+				continue
+			}
 			*findings = append(*findings, ValidationFinding{
 				ResourceType: resType,
 				Path:         path,
 				Name:         name,
-				Required:     blockType.MinItems > 0,
+				Required:     required,
 				IsBlock:      true,
+				Kind:         FindingMissing,
 			})
-			logMissingBlock(t, resType, name, path, blockType.MinItems > 0)
+			logMissingBlock(log, resType, name, path, required)
+			continue
+		}
+
+		// A dynamic block whose for_each is guaranteed empty declares no
+		// instances: it cannot satisfy min_items and has no content to check.
+		// Unknown or conditional for_each values are assumed to be non-empty.
+		if static == nil && bd.dynamicAlwaysEmpty(name) {
+			if blockType.MinItems > 0 {
+				*findings = append(*findings, ValidationFinding{
+					ResourceType: resType,
+					Path:         path,
+					Name:         name,
+					Required:     true,
+					IsBlock:      true,
+					Kind:         FindingInvalid,
+					Message:      "dynamic block for_each is always empty",
+				})
+				log.Logf("%s required dynamic block %s in %s has an empty for_each", resType, name, strings.ReplaceAll(path, "root.", ""))
+			}
 			continue
 		}
 
+		if blockType.deprecated() && opts.reportsDeprecated(resType, name) && !opts.requiredOnly() {
+			*findings = append(*findings, ValidationFinding{
+				ResourceType: resType,
+				Path:         path,
+				Name:         name,
+				IsBlock:      true,
+				Kind:         FindingInvalid,
+				Message:      "uses deprecated block",
+			})
+			log.Logf("%s uses deprecated block %s in %s", resType, name, strings.ReplaceAll(path, "root.", ""))
+		}
+
+		if msg := bd.itemBounds(name, blockType); msg != "" && (blockType.MinItems > 0 || !opts.requiredOnly()) {
+			*findings = append(*findings, ValidationFinding{
+				ResourceType: resType,
+				Path:         path,
+				Name:         name,
+				Required:     blockType.MinItems > 0,
+				IsBlock:      true,
+				Kind:         FindingInvalid,
+				Message:      msg,
+			})
+			log.Logf("%s invalid block %s in %s: %s", resType, name, strings.ReplaceAll(path, "root.", ""), msg)
+		}
+
 		target := static
 		if target == nil {
 			target = dynamic
 		}
 
 		newPath := fmt.Sprintf("%s.%s", path, name)
-		target.data.Validate(t, resType, newPath, blockType.Block, ignore, findings)
+		target.data.Validate(log, resType, newPath, blockType.Block, ignore, opts, findings)
+	}
+}
+
+// resourceMetaArguments are accepted on every resource and data source
+// without appearing in its schema.
+var resourceMetaArguments = []string{"count", "for_each", "provider", "depends_on"}
+
+// validateUnknownAttributes flags attributes set in bd, and in the nested
+// blocks the schema knows, that schema does not define.
+func (bd *BlockData) validateUnknownAttributes(log Logger, resType, path string, schema *SchemaBlock, opts *Options, findings *[]ValidationFinding) {
+	if schema == nil || opts == nil || !opts.CheckUnknownAttributes || opts.RequiredOnly {
+		return
+	}
+
+	names := make([]string, 0, len(bd.properties))
+	for name := range bd.properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if schema.Attributes[name] != nil || schema.BlockTypes[name] != nil {
+			continue
+		}
+		if path == "root" && contains(resourceMetaArguments, name) {
+			continue
+		}
+		*findings = append(*findings, ValidationFinding{
+			ResourceType: resType,
+			Path:         path,
+			Name:         name,
+			Kind:         FindingUnexpected,
+			Message:      "attribute is not defined in the schema",
+		})
+		log.Logf("%s unexpected property %s in %s: attribute is not defined in the schema", resType, name, strings.ReplaceAll(path, "root.", ""))
+	}
+
+	for _, blocks := range []map[string]*ParsedBlock{bd.staticBlocks, bd.dynamicBlocks} {
+		blockNames := make([]string, 0, len(blocks))
+		for name := range blocks {
+			blockNames = append(blockNames, name)
+		}
+		sort.Strings(blockNames)
+		for _, name := range blockNames {
+			if blockType := schema.BlockTypes[name]; blockType != nil {
+				blocks[name].data.validateUnknownAttributes(log, resType, path+"."+name, blockType.Block, opts, findings)
+			}
+		}
+	}
+}
+
+// validateDynamicLabels flags dynamic blocks whose label names no block type
+// in schema, which would otherwise only show up as a missing block.
+func (bd *BlockData) validateDynamicLabels(log Logger, resType, path string, schema *SchemaBlock, findings *[]ValidationFinding) {
+	labels := make([]string, 0, len(bd.dynamicBlocks))
+	for name := range bd.dynamicBlocks {
+		labels = append(labels, name)
+	}
+	for _, name := range bd.missingContent {
+		if bd.dynamicBlocks[name] == nil {
+			labels = append(labels, name)
+		}
+	}
+	sort.Strings(labels)
+
+	for _, name := range labels {
+		if _, ok := schema.BlockTypes[name]; ok {
+			continue
+		}
+		*findings = append(*findings, ValidationFinding{
+			ResourceType: resType,
+			Path:         path,
+			Name:         name,
+			IsBlock:      true,
+			Kind:         FindingInvalid,
+			Message:      "unknown dynamic block",
+		})
+		log.Logf("%s unknown dynamic block %s in %s", resType, name, strings.ReplaceAll(path, "root.", ""))
 	}
 }
 
@@ -234,11 +806,15 @@ func (p *DefaultHCLParser) ParseProviderRequirements(filename string) (map[strin
 						val, _ := attr.Expr.Value(nil)
 						if val.Type().IsObjectType() {
 							pc := ProviderConfig{}
-							if sourceVal := val.GetAttr("source"); !sourceVal.IsNull() {
-								pc.Source = normalizeSource(sourceVal.AsString())
+							if val.Type().HasAttribute("source") {
+								if sourceVal := val.GetAttr("source"); !sourceVal.IsNull() {
+									pc.Source = normalizeSource(sourceVal.AsString())
+								}
 							}
-							if versionVal := val.GetAttr("version"); !versionVal.IsNull() {
-								pc.Version = versionVal.AsString()
+							if val.Type().HasAttribute("version") {
+								if versionVal := val.GetAttr("version"); !versionVal.IsNull() {
+									pc.Version = versionVal.AsString()
+								}
 							}
 							providers[name] = pc
 						}
@@ -251,8 +827,91 @@ func (p *DefaultHCLParser) ParseProviderRequirements(filename string) (map[strin
 }
 
 func (p *DefaultHCLParser) ParseMainFile(filename string) ([]ParsedResource, error) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return p.ParseMainString(string(src), filename)
+}
+
+// ParseModuleFiles parses the resources and data sources of every .tf file in dir except
+// terraform.tf, which holds the provider configuration. A resource address
+// declared more than once is an error, as it is for terraform.
+func (p *DefaultHCLParser) ParseModuleFiles(dir string) ([]ParsedResource, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []ParsedResource
+	seen := make(map[string]hcl.Range)
+	for _, path := range paths {
+		if filepath.Base(path) == "terraform.tf" {
+			continue
+		}
+		parsed, err := p.ParseMainFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", filepath.Base(path), err)
+		}
+		for _, res := range parsed {
+			addr := res.Label() + "." + res.Name
+			if prev, ok := seen[addr]; ok {
+				return nil, fmt.Errorf("duplicate resource %s in %s, first declared at %s", addr, res.rng, prev)
+			}
+			seen[addr] = res.rng
+		}
+		resources = append(resources, parsed...)
+	}
+	return resources, nil
+}
+
+// ListResourceTypes returns the distinct resource types declared across the
+// .tf files in root, sorted. It reads them with ParseModuleFiles, so
+// terraform.tf is skipped and duplicate addresses are errors, and neither
+// fetches schemas nor validates.
+func (p *DefaultHCLParser) ListResourceTypes(root string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(root, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no .tf files found in %s", root)
+	}
+
+	resources, err := p.ParseModuleFiles(root)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var types []string
+	for _, res := range resources {
+		if res.Kind == KindData || seen[res.Type] {
+			continue
+		}
+		seen[res.Type] = true
+		types = append(types, res.Type)
+	}
+	sort.Strings(types)
+	return types, nil
+}
+
+// ParseInput parses resources from path, reading stdin when path is "-".
+func (p *DefaultHCLParser) ParseInput(path string, stdin io.Reader) ([]ParsedResource, error) {
+	if path != "-" {
+		return p.ParseMainFile(path)
+	}
+	src, err := io.ReadAll(stdin)
+	if err != nil {
+		return nil, fmt.Errorf("read stdin: %w", err)
+	}
+	return p.ParseMainString(string(src), "<stdin>")
+}
+
+// ParseMainString parses resources from in-memory HCL; filename is only used
+// for diagnostics and source ranges.
+func (p *DefaultHCLParser) ParseMainString(src, filename string) ([]ParsedResource, error) {
 	parser := hclparse.NewParser()
-	f, diags := parser.ParseHCLFile(filename)
+	f, diags := parser.ParseHCL([]byte(src), filename)
 	if diags.HasErrors() {
 		return nil, fmt.Errorf("parse error: %v", diags)
 	}
@@ -264,12 +923,14 @@ func (p *DefaultHCLParser) ParseMainFile(filename string) ([]ParsedResource, err
 
 	var resources []ParsedResource
 	for _, blk := range body.Blocks {
-		if blk.Type == "resource" && len(blk.Labels) >= 2 {
+		if (blk.Type == "resource" || blk.Type == "data") && len(blk.Labels) >= 2 {
 			parsedBlock := ParseSyntaxBody(blk.Body)
 			res := ParsedResource{
+				Kind: ResourceKind(blk.Type),
 				Type: blk.Labels[0],
 				Name: blk.Labels[1],
 				data: parsedBlock.data,
+				rng:  blk.Range(),
 			}
 			resources = append(resources, res)
 		}
@@ -277,303 +938,3580 @@ func (p *DefaultHCLParser) ParseMainFile(filename string) ([]ParsedResource, err
 	return resources, nil
 }
 
-// GitHub implementation
-type GitHubIssueService struct {
-	RepoOwner string
-	RepoName  string
-	token     string
-	Client    *http.Client
-}
-
-func (g *GitHubIssueService) CreateOrUpdateIssue(findings []ValidationFinding) error {
-	if len(findings) == 0 {
-		return nil
+func (p *DefaultHCLParser) ParseTfvars(filename string) (map[string]cty.Value, error) {
+	parser := hclparse.NewParser()
+	f, diags := parser.ParseHCLFile(filename)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("parse error: %v", diags)
 	}
 
-	const header = "### \n\n"
-	uniqueFindings := make(map[string]ValidationFinding)
-
-	// Deduplicate findings
-	for _, f := range findings {
-		key := fmt.Sprintf("%s|%s|%s|%v",
-			f.ResourceType,
-			strings.ReplaceAll(f.Path, "root.", ""),
-			f.Name,
-			f.IsBlock,
-		)
-		uniqueFindings[key] = f
+	attrs, diags := f.Body.JustAttributes()
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("invalid tfvars: %v", diags)
 	}
 
-	var newBody bytes.Buffer
-	fmt.Fprint(&newBody, header)
-
-	// Format findings with line breaks
-	for _, f := range uniqueFindings {
-		cleanPath := strings.ReplaceAll(f.Path, "root.", "")
-		status := "optional"
-		if f.Required {
-			status = "required"
-		}
-		itemType := "block"
-		if !f.IsBlock {
-			itemType = "property"
+	vars := make(map[string]cty.Value, len(attrs))
+	for name, attr := range attrs {
+		val, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("invalid value for %s: %v", name, diags)
 		}
-
-		fmt.Fprintf(&newBody, "`%s`: Missing %s %s `%s` in %s\n\n", // Note double newline
-			f.ResourceType,
-			status,
-			itemType,
-			f.Name,
-			cleanPath,
-		)
+		vars[name] = val
 	}
+	return vars, nil
+}
 
-	title := "Generated schema validation"
-	issueNumber, existingBody, err := g.findExistingIssue(title)
+// ParseDeclaredAddresses returns the addresses of every resource, data source
+// and module call declared in filename, e.g. "azurerm_x.y", "data.azurerm_x.y"
+// and "module.y".
+func (p *DefaultHCLParser) ParseDeclaredAddresses(filename string) (map[string]bool, error) {
+	body, err := parseSyntaxFile(filename)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	finalBody := newBody.String()
-	if issueNumber > 0 {
-		existingParts := strings.SplitN(existingBody, header, 2)
-		if len(existingParts) > 0 {
-			finalBody = strings.TrimSpace(existingParts[0]) + "\n\n" + newBody.String()
+	addresses := make(map[string]bool)
+	for _, blk := range body.Blocks {
+		switch {
+		case blk.Type == "resource" && len(blk.Labels) >= 2:
+			addresses[blk.Labels[0]+"."+blk.Labels[1]] = true
+		case blk.Type == "data" && len(blk.Labels) >= 2:
+			addresses["data."+blk.Labels[0]+"."+blk.Labels[1]] = true
+		case blk.Type == "module" && len(blk.Labels) == 1:
+			addresses["module."+blk.Labels[0]] = true
 		}
 	}
-
-	if issueNumber > 0 {
-		return g.updateIssue(issueNumber, finalBody)
-	}
-	return g.createIssue(title, finalBody)
+	return addresses, nil
 }
 
-func (g *GitHubIssueService) findExistingIssue(title string) (int, string, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/issues?state=open", g.RepoOwner, g.RepoName)
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Set("Authorization", "token "+g.token)
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-
-	resp, err := g.Client.Do(req)
+func (p *DefaultHCLParser) ParseProviderBlocks(filename string) ([]ParsedProvider, error) {
+	body, err := parseSyntaxFile(filename)
 	if err != nil {
-		return 0, "", err
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return 0, "", fmt.Errorf("GitHub API error: %s", resp.Status)
+	var providers []ParsedProvider
+	for _, blk := range body.Blocks {
+		if blk.Type != "provider" || len(blk.Labels) != 1 {
+			continue
+		}
+		parsed := ParseSyntaxBody(blk.Body)
+		provider := ParsedProvider{Name: blk.Labels[0], data: parsed.data}
+		if attr, ok := parsed.data.attributes["alias"]; ok {
+			if val, ok := literalValue(attr.Expr, nil); ok && val.Type() == cty.String {
+				provider.Alias = val.AsString()
+			}
+			delete(provider.data.attributes, "alias")
+			delete(provider.data.properties, "alias")
+		}
+		providers = append(providers, provider)
 	}
+	return providers, nil
+}
 
-	var issues []struct {
-		Number int    `json:"number"`
-		Title  string `json:"title"`
-		Body   string `json:"body"`
+func (p *DefaultHCLParser) ParseOutputs(filename string) ([]ParsedOutput, error) {
+	body, err := parseSyntaxFile(filename)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&issues); err != nil {
-		return 0, "", err
+	var outputs []ParsedOutput
+	for _, blk := range body.Blocks {
+		if blk.Type != "output" || len(blk.Labels) != 1 {
+			continue
+		}
+		output := ParsedOutput{Name: blk.Labels[0]}
+		if attr, ok := blk.Body.Attributes["value"]; ok {
+			output.Value = attr.Expr
+		}
+		if attr, ok := blk.Body.Attributes["sensitive"]; ok {
+			if val, ok := literalValue(attr.Expr, nil); ok && val.Type() == cty.Bool {
+				output.Sensitive = val.True()
+			}
+		}
+		outputs = append(outputs, output)
 	}
+	return outputs, nil
+}
 
+// ParseCloudBlock returns the terraform { cloud {} } block of filename, or
+// nil when there is none.
+func (p *DefaultHCLParser) ParseCloudBlock(filename string) (*ParsedBlock, error) {
+	body, err := parseSyntaxFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, blk := range body.Blocks {
+		if blk.Type != "terraform" {
+			continue
+		}
+		for _, inner := range blk.Body.Blocks {
+			if inner.Type == "cloud" {
+				return ParseSyntaxBody(inner.Body), nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// ParseRequiredVersion returns the literal terraform { required_version }
+// constraint in filename, or "" when none is set.
+func (p *DefaultHCLParser) ParseRequiredVersion(filename string) (string, error) {
+	body, err := parseSyntaxFile(filename)
+	if err != nil {
+		return "", err
+	}
+
+	for _, blk := range body.Blocks {
+		if blk.Type != "terraform" {
+			continue
+		}
+		if attr, ok := blk.Body.Attributes["required_version"]; ok {
+			if val, ok := literalValue(attr.Expr, nil); ok && val.Type() == cty.String {
+				return val.AsString(), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+func (p *DefaultHCLParser) ParseMovedBlocks(filename string) ([]ParsedMoved, error) {
+	body, err := parseSyntaxFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var moved []ParsedMoved
+	for _, blk := range body.Blocks {
+		if blk.Type != "moved" {
+			continue
+		}
+		var m ParsedMoved
+		for name, dst := range map[string]*string{"from": &m.From, "to": &m.To} {
+			attr, ok := blk.Body.Attributes[name]
+			if !ok {
+				continue
+			}
+			if traversal, diags := hcl.AbsTraversalForExpr(attr.Expr); !diags.HasErrors() {
+				*dst = referenceAddress(traversal)
+			}
+		}
+		moved = append(moved, m)
+	}
+	return moved, nil
+}
+
+// ParseModuleCalls returns the module blocks in filename with their literal
+// source.
+func (p *DefaultHCLParser) ParseModuleCalls(filename string) ([]ParsedModuleCall, error) {
+	body, err := parseSyntaxFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var calls []ParsedModuleCall
+	for _, blk := range body.Blocks {
+		if blk.Type != "module" || len(blk.Labels) != 1 {
+			continue
+		}
+		call := ParsedModuleCall{Name: blk.Labels[0]}
+		if attr, ok := blk.Body.Attributes["source"]; ok {
+			if val, diags := attr.Expr.Value(nil); !diags.HasErrors() && val.Type() == cty.String && val.IsKnown() && !val.IsNull() {
+				call.Source = val.AsString()
+			}
+		}
+		calls = append(calls, call)
+	}
+	return calls, nil
+}
+
+// ParseVariables returns the names of the variable blocks in filename.
+func (p *DefaultHCLParser) ParseVariables(filename string) ([]string, error) {
+	body, err := parseSyntaxFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, blk := range body.Blocks {
+		if blk.Type == "variable" && len(blk.Labels) == 1 {
+			names = append(names, blk.Labels[0])
+		}
+	}
+	return names, nil
+}
+
+// ParseVariableReferences collects the var.<name> references made anywhere in
+// filename, including locals and module arguments. Variable blocks are
+// skipped so a validation rule does not count as a use of its own variable.
+func (p *DefaultHCLParser) ParseVariableReferences(filename string) (map[string]bool, error) {
+	body, err := parseSyntaxFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make(map[string]bool)
+	var scan func(body *hclsyntax.Body)
+	scan = func(body *hclsyntax.Body) {
+		for _, attr := range body.Attributes {
+			for _, traversal := range attr.Expr.Variables() {
+				if traversal.RootName() != "var" || len(traversal) < 2 {
+					continue
+				}
+				if step, ok := traversal[1].(hcl.TraverseAttr); ok {
+					refs[step.Name] = true
+				}
+			}
+		}
+		for _, blk := range body.Blocks {
+			if blk.Type != "variable" {
+				scan(blk.Body)
+			}
+		}
+	}
+	scan(body)
+	return refs, nil
+}
+
+// ParseLocals returns the expressions of the locals blocks in filename by name.
+func (p *DefaultHCLParser) ParseLocals(filename string) (map[string]hclsyntax.Expression, error) {
+	body, err := parseSyntaxFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	locals := make(map[string]hclsyntax.Expression)
+	for _, blk := range body.Blocks {
+		if blk.Type != "locals" {
+			continue
+		}
+		for name, attr := range blk.Body.Attributes {
+			locals[name] = attr.Expr
+		}
+	}
+	return locals, nil
+}
+
+func parseSyntaxFile(filename string) (*hclsyntax.Body, error) {
+	parser := hclparse.NewParser()
+	f, diags := parser.ParseHCLFile(filename)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("parse error: %v", diags)
+	}
+
+	body, ok := f.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, fmt.Errorf("invalid body type")
+	}
+	return body, nil
+}
+
+// Policy checks
+func LoadOptions() (*Options, error) {
+	opts := &Options{}
+	if path := os.Getenv("GOPHX_CONFIG"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read config: %w", err)
+		}
+		if err := json.Unmarshal(data, opts); err != nil {
+			return nil, fmt.Errorf("decode config: %w", err)
+		}
+	}
+	if path := os.Getenv("GOPHX_SCHEMA_FILE"); path != "" {
+		opts.SchemaFile = path
+	}
+	if envEnabled("GOPHX_REQUIRE_TAGS") {
+		opts.RequireTags = true
+	}
+	if envEnabled("GOPHX_CHECK_DEPRECATED") {
+		opts.CheckDeprecated = true
+	}
+	if envEnabled("GOPHX_REQUIRE_COMMENTS") {
+		opts.RequireComments = true
+	}
+	if envEnabled("GOPHX_REQUIRE_INDEXED_NAMES") {
+		opts.RequireIndexedNames = true
+	}
+	if envEnabled("GOPHX_REQUIRE_PROVIDER_VERSIONS") {
+		opts.RequireProviderVersions = true
+	}
+	if envEnabled("GOPHX_CHECK_UNKNOWN_ATTRIBUTES") {
+		opts.CheckUnknownAttributes = true
+	}
+	if envEnabled("GOPHX_FMT_CHECK") {
+		opts.FmtCheck = true
+	}
+	if envEnabled("GOPHX_REQUIRED_ONLY") {
+		opts.RequiredOnly = true
+	}
+	if n, err := strconv.Atoi(os.Getenv("GOPHX_MAX_DEPENDS_ON")); err == nil && n > 0 {
+		opts.MaxDependsOn = n
+	}
+	if names := envList("GOPHX_VARIABLE_ATTRIBUTES"); len(names) > 0 {
+		opts.VariableAttributes = names
+	}
+	if keys := envList("GOPHX_REQUIRED_TAG_KEYS"); len(keys) > 0 {
+		opts.RequiredTagKeys = keys
+	}
+	if types := envList("GOPHX_ONLY_TYPES"); len(types) > 0 {
+		opts.OnlyTypes = types
+	}
+	if types := envList("GOPHX_SKIP_TYPES"); len(types) > 0 {
+		opts.SkipTypes = types
+	}
+	if pattern := os.Getenv("GOPHX_NAME_PATTERN"); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GOPHX_NAME_PATTERN: %w", err)
+		}
+		opts.NamePattern = re
+	}
+	return opts, nil
+}
+
+func (o *Options) logger() Logger {
+	if o == nil || o.Logger == nil {
+		return discardLogger{}
+	}
+	return o.Logger
+}
+
+// envList splits a comma-separated environment variable, dropping blanks.
+func envList(name string) []string {
+	var out []string
+	for _, v := range strings.Split(os.Getenv(name), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// reportsDeprecated reports whether a set deprecated attribute should produce
+// a finding.
+func (o *Options) reportsDeprecated(resType, name string) bool {
+	return o != nil && o.CheckDeprecated && !contains(o.DeprecatedAllowlist, resType+":"+name)
+}
+
+func (o *Options) requiredOnly() bool {
+	return o != nil && o.RequiredOnly
+}
+
+// includesType applies SkipTypes, then OnlyTypes.
+func (o *Options) includesType(resType string) bool {
+	if o == nil {
+		return true
+	}
+	if contains(o.SkipTypes, resType) {
+		return false
+	}
+	return len(o.OnlyTypes) == 0 || contains(o.OnlyTypes, resType)
+}
+
+func envEnabled(name string) bool {
+	v, _ := strconv.ParseBool(os.Getenv(name))
+	return v
+}
+
+// ResourceValidator is a custom check run on a resource and its schema.
+type ResourceValidator func(res ParsedResource, schema *ResourceSchema) []ValidationFinding
+
+// RegisterValidator adds v to the custom checks run by ValidateModule.
+func (o *Options) RegisterValidator(v ResourceValidator) {
+	o.Validators = append(o.Validators, v)
+}
+
+// runValidators appends the findings of the registered validators, filling
+// in the resource type, root path and invalid kind when left empty.
+func runValidators(log Logger, res ParsedResource, schema *ResourceSchema, opts *Options, findings *[]ValidationFinding) {
+	if opts == nil {
+		return
+	}
+	for _, v := range opts.Validators {
+		for _, f := range v(res, schema) {
+			if f.ResourceType == "" {
+				f.ResourceType = res.Type
+			}
+			if f.Path == "" {
+				f.Path = "root"
+			}
+			if f.Kind == "" {
+				f.Kind = FindingInvalid
+			}
+			if !f.Required && opts.RequiredOnly {
+				continue
+			}
+			*findings = append(*findings, f)
+			log.Logf("%s invalid property %s in %s: %s", f.ResourceType, f.Name, strings.ReplaceAll(f.Path, "root.", ""), f.Message)
+		}
+	}
+}
+
+// requiresAttribute reports whether policy upgrades a missing optional attribute to required.
+func (o *Options) requiresAttribute(resType, path, name string) bool {
+	if o == nil || path != "root" {
+		return false
+	}
+	if o.RequireTags && name == "tags" {
+		return true
+	}
+	return contains(o.RequiredAttributes[resType], name)
+}
+
+// requiresBlock reports whether a missing optional block at path is upgraded
+// to required by the RequiredBlocks overlay.
+func (o *Options) requiresBlock(resType, path, name string) bool {
+	return o != nil && path == "root" && contains(o.RequiredBlocks[resType], name)
+}
+
+// NewEvalContext exposes tfvars values as var.<name> to attribute expressions.
+func NewEvalContext(vars map[string]cty.Value) *hcl.EvalContext {
+	varsVal := cty.EmptyObjectVal
+	if len(vars) > 0 {
+		varsVal = cty.ObjectVal(vars)
+	}
+	return &hcl.EvalContext{
+		Variables: map[string]cty.Value{"var": varsVal},
+	}
+}
+
+// withLocals extends ctx with local.<name> for every local that evaluates to
+// a known value, and with the merge function. Locals referring to other
+// locals are resolved over repeated passes.
+func withLocals(ctx *hcl.EvalContext, locals map[string]hclsyntax.Expression) *hcl.EvalContext {
+	child := ctx.NewChild()
+	child.Functions = map[string]function.Function{"merge": stdlib.MergeFunc}
+
+	values := make(map[string]cty.Value)
+	for progress := true; progress; {
+		progress = false
+		child.Variables = map[string]cty.Value{"local": cty.ObjectVal(values)}
+		for name, expr := range locals {
+			if _, done := values[name]; done {
+				continue
+			}
+			if val, ok := literalValue(expr, child); ok {
+				values[name] = val
+				progress = true
+			}
+		}
+	}
+	child.Variables = map[string]cty.Value{"local": cty.ObjectVal(values)}
+	return child
+}
+
+// validateTagKeys reports resources whose tags omit opts.RequiredTagKeys.
+// Tags that cannot be resolved to a known map are skipped.
+func validateTagKeys(log Logger, res ParsedResource, opts *Options, ctx *hcl.EvalContext, findings *[]ValidationFinding) {
+	if opts == nil || len(opts.RequiredTagKeys) == 0 {
+		return
+	}
+	attr := res.data.attributes["tags"]
+	if attr == nil {
+		return
+	}
+	val, ok := literalValue(attr.Expr, ctx)
+	if !ok || !(val.Type().IsObjectType() || val.Type().IsMapType()) {
+		return
+	}
+
+	var missing []string
+	for _, key := range opts.RequiredTagKeys {
+		has := val.Type().IsObjectType() && val.Type().HasAttribute(key) ||
+			val.Type().IsMapType() && val.HasIndex(cty.StringVal(key)).True()
+		if !has {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+	msg := fmt.Sprintf("tags missing required keys %s", strings.Join(missing, ", "))
+	*findings = append(*findings, ValidationFinding{
+		ResourceType: res.Type,
+		Path:         "root",
+		Name:         "tags",
+		Kind:         FindingInvalid,
+		Message:      msg,
+	})
+	log.Logf("%s invalid property tags in root: %s", res.Type, msg)
+}
+
+func validateAllowedValues(log Logger, res ParsedResource, opts *Options, ctx *hcl.EvalContext, findings *[]ValidationFinding) {
+	if opts == nil {
+		return
+	}
+	for name, allowed := range opts.AllowedValues[res.Type] {
+		attr := res.data.attributes[name]
+		if attr == nil {
+			continue
+		}
+		val, ok := literalValue(attr.Expr, ctx)
+		if !ok || val.Type() != cty.String {
+			continue
+		}
+		if !contains(allowed, val.AsString()) {
+			msg := fmt.Sprintf("value %q is not one of %s", val.AsString(), strings.Join(allowed, ", "))
+			*findings = append(*findings, ValidationFinding{
+				ResourceType: res.Type,
+				Path:         "root",
+				Name:         name,
+				Kind:         FindingInvalid,
+				Message:      msg,
+			})
+			log.Logf("%s invalid property %s in root: %s", res.Type, name, msg)
+		}
+	}
+}
+
+// NumericRange bounds a numeric attribute; a nil end is unbounded.
+type NumericRange struct {
+	Min *float64 `json:"min"`
+	Max *float64 `json:"max"`
+}
+
+// validateNumericRanges checks literal numbers against opts.NumericRanges.
+// Values that depend on references are skipped.
+func validateNumericRanges(log Logger, res ParsedResource, opts *Options, findings *[]ValidationFinding) {
+	if opts == nil {
+		return
+	}
+	ranges := opts.NumericRanges[res.Type]
+	names := make([]string, 0, len(ranges))
+	for name := range ranges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		attr := res.data.attributes[name]
+		if attr == nil {
+			continue
+		}
+		val, ok := literalValue(attr.Expr, nil)
+		if !ok || val.Type() != cty.Number {
+			continue
+		}
+		n, bounds := val.AsBigFloat(), ranges[name]
+		var msg string
+		switch {
+		case bounds.Min != nil && n.Cmp(big.NewFloat(*bounds.Min)) < 0:
+			msg = fmt.Sprintf("value %s is below minimum %s", n.Text('g', -1), strconv.FormatFloat(*bounds.Min, 'g', -1, 64))
+		case bounds.Max != nil && n.Cmp(big.NewFloat(*bounds.Max)) > 0:
+			msg = fmt.Sprintf("value %s exceeds maximum %s", n.Text('g', -1), strconv.FormatFloat(*bounds.Max, 'g', -1, 64))
+		default:
+			continue
+		}
+		*findings = append(*findings, ValidationFinding{
+			ResourceType: res.Type,
+			Path:         "root",
+			Name:         name,
+			Kind:         FindingInvalid,
+			Message:      msg,
+		})
+		log.Logf("%s invalid property %s in root: %s", res.Type, name, msg)
+	}
+}
+
+func validateResourceName(log Logger, res ParsedResource, opts *Options, findings *[]ValidationFinding) {
+	if opts == nil || opts.NamePattern == nil || opts.NamePattern.MatchString(res.Name) {
+		return
+	}
+	msg := fmt.Sprintf("resource name does not match %s", opts.NamePattern)
+	*findings = append(*findings, ValidationFinding{
+		ResourceType: res.Type,
+		Path:         "root",
+		Name:         res.Name,
+		Kind:         FindingInvalid,
+		Message:      msg,
+	})
+	log.Logf("%s invalid name %s: %s", res.Type, res.Name, msg)
+}
+
+func validatePreventDestroy(log Logger, res ParsedResource, opts *Options, findings *[]ValidationFinding) {
+	if opts == nil || !res.data.preventDestroy || !contains(opts.NoPreventDestroyTypes, res.Type) {
+		return
+	}
+	msg := "prevent_destroy must not be set on this resource type"
+	*findings = append(*findings, ValidationFinding{
+		ResourceType: res.Type,
+		Path:         "root",
+		Name:         "lifecycle",
+		IsBlock:      true,
+		Kind:         FindingInvalid,
+		Message:      msg,
+	})
+	log.Logf("%s invalid block lifecycle in root: %s", res.Type, msg)
+}
+
+// validateRepetition rejects resources that set both count and for_each.
+func validateRepetition(log Logger, res ParsedResource, findings *[]ValidationFinding) {
+	if res.data.attributes["count"] == nil || res.data.attributes["for_each"] == nil {
+		return
+	}
+	msg := "count and for_each cannot both be set"
+	*findings = append(*findings, ValidationFinding{
+		ResourceType: res.Type,
+		Path:         "root",
+		Name:         "for_each",
+		Kind:         FindingInvalid,
+		Message:      msg,
+	})
+	log.Logf("%s invalid property for_each in root: %s", res.Type, msg)
+}
+
+// validateIndexedNames requires multi-instance resources to vary a name-like
+// attribute or tags by instance, so the instances do not collide.
+func validateIndexedNames(log Logger, res ParsedResource, opts *Options, findings *[]ValidationFinding) {
+	if opts == nil || !opts.RequireIndexedNames {
+		return
+	}
+	meta := "count"
+	if res.data.attributes["count"] == nil {
+		if res.data.attributes["for_each"] == nil {
+			return
+		}
+		meta = "for_each"
+	}
+
+	var candidates []string
+	for name := range res.data.attributes {
+		if name == "name" || name == "tags" || strings.HasSuffix(name, "_name") {
+			candidates = append(candidates, name)
+		}
+	}
+	if len(candidates) == 0 {
+		return
+	}
+	sort.Strings(candidates)
+
+	for _, name := range candidates {
+		for _, traversal := range res.data.attributes[name].Expr.Variables() {
+			if instanceReference(traversal) {
+				return
+			}
+		}
+	}
+
+	msg := fmt.Sprintf("%s resource does not reference its instance key in %s", meta, strings.Join(candidates, ", "))
+	*findings = append(*findings, ValidationFinding{
+		ResourceType: res.Type,
+		Path:         "root",
+		Name:         candidates[0],
+		Kind:         FindingInvalid,
+		Message:      msg,
+	})
+	log.Logf("%s invalid property %s in root: %s", res.Type, candidates[0], msg)
+}
+
+// instanceReference reports whether traversal is count.index, each.key or
+// each.value.
+func instanceReference(traversal hcl.Traversal) bool {
+	if len(traversal) < 2 {
+		return false
+	}
+	attr, ok := traversal[1].(hcl.TraverseAttr)
+	if !ok {
+		return false
+	}
+	switch traversal.RootName() {
+	case "count":
+		return attr.Name == "index"
+	case "each":
+		return attr.Name == "key" || attr.Name == "value"
+	}
+	return false
+}
+
+func validateDependsOn(log Logger, res ParsedResource, declared map[string]bool, findings *[]ValidationFinding) {
+	attr := res.data.attributes["depends_on"]
+	if attr == nil {
+		return
+	}
+	exprs, diags := hcl.ExprList(attr.Expr)
+	if diags.HasErrors() {
+		return
+	}
+
+	for _, expr := range exprs {
+		traversal, diags := hcl.AbsTraversalForExpr(expr)
+		if diags.HasErrors() {
+			continue
+		}
+		addr := referenceAddress(traversal)
+		if addr == "" || declared[addr] {
+			continue
+		}
+		msg := fmt.Sprintf("references undeclared %s", addr)
+		*findings = append(*findings, ValidationFinding{
+			ResourceType: res.Label(),
+			Path:         "root",
+			Name:         "depends_on",
+			Kind:         FindingInvalid,
+			Message:      msg,
+		})
+		log.Logf("%s invalid property depends_on in root: %s", res.Label(), msg)
+	}
+}
+
+// validateDependsOnCount reports depends_on lists longer than
+// opts.MaxDependsOn as informational findings.
+func validateDependsOnCount(log Logger, res ParsedResource, opts *Options, findings *[]ValidationFinding) {
+	attr := res.data.attributes["depends_on"]
+	if opts == nil || opts.MaxDependsOn <= 0 || attr == nil {
+		return
+	}
+	exprs, diags := hcl.ExprList(attr.Expr)
+	if diags.HasErrors() || len(exprs) <= opts.MaxDependsOn {
+		return
+	}
+	msg := fmt.Sprintf("%d explicit dependencies, more than %d; prefer implicit references", len(exprs), opts.MaxDependsOn)
+	*findings = append(*findings, ValidationFinding{
+		ResourceType: res.Type,
+		Path:         "root",
+		Name:         "depends_on",
+		Kind:         FindingInfo,
+		Message:      msg,
+	})
+	log.Logf("%s depends_on in root: %s", res.Type, msg)
+}
+
+// validateDataReferences flags data.<type>.<name> references in attribute
+// values, including nested blocks, that have no matching data block.
+func validateDataReferences(log Logger, res ParsedResource, declared map[string]bool, findings *[]ValidationFinding) {
+	res.data.walk("root", func(path string, bd *BlockData) {
+		names := make([]string, 0, len(bd.attributes))
+		for name := range bd.attributes {
+			if name != "depends_on" {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			for _, traversal := range bd.attributes[name].Expr.Variables() {
+				if traversal.RootName() != "data" {
+					continue
+				}
+				addr := referenceAddress(traversal)
+				if addr == "" || declared[addr] {
+					continue
+				}
+				msg := fmt.Sprintf("references undeclared %s", addr)
+				*findings = append(*findings, ValidationFinding{
+					ResourceType: res.Label(),
+					Path:         path,
+					Name:         name,
+					Kind:         FindingInvalid,
+					Message:      msg,
+				})
+				log.Logf("%s invalid property %s in %s: %s", res.Label(), name, strings.ReplaceAll(path, "root.", ""), msg)
+			}
+		}
+	})
+}
+
+// referenceAddress trims a traversal to the address of the object it refers
+// to, dropping attribute and index steps.
+func referenceAddress(traversal hcl.Traversal) string {
+	parts := []string{traversal.RootName()}
+	want := 2
+	switch parts[0] {
+	case "data":
+		want = 3
+	case "var", "local", "each", "count", "path", "terraform", "self":
+		return ""
+	}
+
+	for _, step := range traversal[1:] {
+		if len(parts) == want {
+			break
+		}
+		attr, ok := step.(hcl.TraverseAttr)
+		if !ok {
+			break
+		}
+		parts = append(parts, attr.Name)
+	}
+	if len(parts) != want {
+		return ""
+	}
+	return strings.Join(parts, ".")
+}
+
+func validateCIDRs(log Logger, res ParsedResource, opts *Options, ctx *hcl.EvalContext, findings *[]ValidationFinding) {
+	if opts == nil || len(opts.CIDRAttributes) == 0 {
+		return
+	}
+	res.data.walk("root", func(path string, bd *BlockData) {
+		for _, name := range opts.CIDRAttributes {
+			attr := bd.attributes[name]
+			if attr == nil {
+				continue
+			}
+			val, ok := literalValue(attr.Expr, ctx)
+			if !ok {
+				continue
+			}
+			for _, cidr := range literalStrings(val) {
+				if _, _, err := net.ParseCIDR(cidr); err == nil {
+					continue
+				}
+				msg := fmt.Sprintf("%q is not a valid CIDR", cidr)
+				*findings = append(*findings, ValidationFinding{
+					ResourceType: res.Type,
+					Path:         path,
+					Name:         name,
+					Kind:         FindingInvalid,
+					Message:      msg,
+				})
+				log.Logf("%s invalid property %s in %s: %s", res.Type, name, strings.ReplaceAll(path, "root.", ""), msg)
+			}
+		}
+	})
+}
+
+// validateHardcodedValues flags opts.VariableAttributes set to literal
+// strings anywhere in the resource.
+func validateHardcodedValues(log Logger, res ParsedResource, opts *Options, findings *[]ValidationFinding) {
+	if opts == nil || len(opts.VariableAttributes) == 0 {
+		return
+	}
+	res.data.walk("root", func(path string, bd *BlockData) {
+		for _, name := range opts.VariableAttributes {
+			attr := bd.attributes[name]
+			if attr == nil || len(attr.Expr.Variables()) > 0 {
+				continue
+			}
+			val, ok := literalValue(attr.Expr, nil)
+			if !ok || val.Type() != cty.String {
+				continue
+			}
+			msg := fmt.Sprintf("hardcoded value %q should reference a variable", val.AsString())
+			*findings = append(*findings, ValidationFinding{
+				ResourceType: res.Type,
+				Path:         path,
+				Name:         name,
+				Kind:         FindingInvalid,
+				Message:      msg,
+			})
+			log.Logf("%s invalid property %s in %s: %s", res.Type, name, strings.ReplaceAll(path, "root.", ""), msg)
+		}
+	})
+}
+
+// literalStrings returns val itself when it is a string, or its string
+// elements when it is a list, set or tuple.
+func literalStrings(val cty.Value) []string {
+	if val.Type() == cty.String {
+		return []string{val.AsString()}
+	}
+	var out []string
+	if val.CanIterateElements() && !val.Type().IsMapType() && !val.Type().IsObjectType() {
+		for it := val.ElementIterator(); it.Next(); {
+			_, v := it.Element()
+			if v.IsKnown() && !v.IsNull() && v.Type() == cty.String {
+				out = append(out, v.AsString())
+			}
+		}
+	}
+	return out
+}
+
+// SourceCache holds raw file contents keyed by filename.
+type SourceCache map[string][]byte
+
+func (c SourceCache) read(filename string) ([]byte, error) {
+	if src, ok := c[filename]; ok {
+		return src, nil
+	}
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	c[filename] = src
+	return src, nil
+}
+
+var commentedBlockPattern = regexp.MustCompile(`^(?:dynamic\s+"([\w-]+)"|([A-Za-z_][\w-]*))\s*\{`)
+
+// annotateCommentedBlocks marks missing required block findings of res whose
+// block still appears, commented out, inside the resource's source range.
+func annotateCommentedBlocks(log Logger, res ParsedResource, sources SourceCache, findings []ValidationFinding) {
+	if res.rng.Filename == "" {
+		return
+	}
+	src, err := sources.read(res.rng.Filename)
+	if err != nil || res.rng.End.Byte > len(src) {
+		return
+	}
+	commented := commentedBlocks(src[res.rng.Start.Byte:res.rng.End.Byte])
+
+	for i := range findings {
+		f := &findings[i]
+		if f.Kind != FindingMissing || !f.IsBlock || !f.Required || !commented[f.Name] {
+			continue
+		}
+		f.Message = "block is present but commented out"
+		log.Logf("%s required block %s in %s is commented out", f.ResourceType, f.Name, strings.ReplaceAll(f.Path, "root.", ""))
+	}
+}
+
+// validateResourceComment requires a non-empty comment directly above the
+// resource block when RequireComments is set.
+func validateResourceComment(log Logger, res ParsedResource, sources SourceCache, opts *Options, findings *[]ValidationFinding) {
+	if opts == nil || !opts.RequireComments || res.rng.Filename == "" {
+		return
+	}
+	src, err := sources.read(res.rng.Filename)
+	if err != nil || res.rng.Start.Byte > len(src) {
+		return
+	}
+	if hasLeadComment(src, res.rng.Start.Byte) {
+		return
+	}
+	msg := fmt.Sprintf("resource %s has no leading comment", res.Name)
+	*findings = append(*findings, ValidationFinding{
+		ResourceType: res.Type,
+		Path:         "root",
+		Name:         "comment",
+		Kind:         FindingInvalid,
+		Message:      msg,
+	})
+	log.Logf("%s invalid comment in root: %s", res.Type, msg)
+}
+
+// hasLeadComment reports whether the token ending right before offset is a
+// comment with text. Line comments carry their own newline; block comments
+// are followed by one.
+func hasLeadComment(src []byte, offset int) bool {
+	tokens, _ := hclsyntax.LexConfig(src[:offset], "", hcl.InitialPos)
+	i := len(tokens) - 1
+	for i >= 0 && tokens[i].Type == hclsyntax.TokenEOF {
+		i--
+	}
+	if i >= 1 && tokens[i].Type == hclsyntax.TokenNewline && tokens[i-1].Type == hclsyntax.TokenComment &&
+		strings.HasSuffix(string(tokens[i-1].Bytes), "*/") {
+		i--
+	}
+	if i < 0 || tokens[i].Type != hclsyntax.TokenComment {
+		return false
+	}
+	text := strings.TrimSpace(string(tokens[i].Bytes))
+	for _, marker := range []string{"#", "//", "/*"} {
+		text = strings.TrimPrefix(text, marker)
+	}
+	return strings.TrimSpace(strings.TrimSuffix(text, "*/")) != ""
+}
+
+// commentedBlocks returns the names of blocks opened inside comments in src.
+func commentedBlocks(src []byte) map[string]bool {
+	names := make(map[string]bool)
+	tokens, _ := hclsyntax.LexConfig(src, "", hcl.InitialPos)
+	for _, tok := range tokens {
+		if tok.Type != hclsyntax.TokenComment {
+			continue
+		}
+		for _, line := range strings.Split(string(tok.Bytes), "\n") {
+			line = strings.TrimSpace(line)
+			for _, marker := range []string{"#", "//", "/*", "*"} {
+				line = strings.TrimSpace(strings.TrimPrefix(line, marker))
+			}
+			if m := commentedBlockPattern.FindStringSubmatch(line); m != nil {
+				names[m[1]+m[2]] = true
+			}
+		}
+	}
+	return names
+}
+
+// defaultSensitivePattern matches names whose last underscore-separated
+// segment is password, secret or key, so primary_access_key is flagged but
+// key_vault_id and monkey are not.
+var defaultSensitivePattern = regexp.MustCompile(`(?i)(^|_)(password|secret|key)$`)
+
+func (o *Options) sensitivePattern() *regexp.Regexp {
+	if o == nil || o.SensitivePattern == nil {
+		return defaultSensitivePattern
+	}
+	return o.SensitivePattern
+}
+
+const sensitiveOutputMessage = "output exposes a secret but is not marked sensitive"
+
+// validateSensitiveOutputs flags outputs that look like they expose a secret,
+// by name or by a referenced attribute, without sensitive = true.
+func validateSensitiveOutputs(log Logger, outputs []ParsedOutput, opts *Options, findings *[]ValidationFinding) {
+	pattern := opts.sensitivePattern()
+	for _, output := range outputs {
+		if output.Sensitive {
+			continue
+		}
+		exposes := pattern.MatchString(output.Name)
+		if !exposes && output.Value != nil {
+			for _, traversal := range output.Value.Variables() {
+				if name := lastAttrName(traversal); name != "" && pattern.MatchString(name) {
+					exposes = true
+					break
+				}
+			}
+		}
+		if !exposes {
+			continue
+		}
+		msg := sensitiveOutputMessage
+		*findings = append(*findings, ValidationFinding{
+			ResourceType: "output",
+			Path:         "root",
+			Name:         output.Name,
+			Kind:         FindingInvalid,
+			Message:      msg,
+		})
+		log.Logf("output %s: %s", output.Name, msg)
+	}
+}
+
+// validateMovedBlocks requires each moved block to point at a declared
+// address and to start from one that no longer exists.
+func validateMovedBlocks(log Logger, moved []ParsedMoved, declared map[string]bool, findings *[]ValidationFinding) {
+	for _, m := range moved {
+		var name, msg string
+		switch {
+		case m.To == "" || m.From == "":
+			continue
+		case !declared[m.To]:
+			name, msg = "to", fmt.Sprintf("moved from %s to undeclared %s", m.From, m.To)
+		case declared[m.From]:
+			name, msg = "from", fmt.Sprintf("moved from %s, which is still declared", m.From)
+		default:
+			continue
+		}
+		*findings = append(*findings, ValidationFinding{
+			ResourceType: "moved",
+			Path:         "root",
+			Name:         name,
+			Kind:         FindingInvalid,
+			Message:      msg,
+		})
+		log.Logf("moved block %s: %s", name, msg)
+	}
+}
+
+// validateUnusedVariables flags declared variables that nothing references.
+func validateUnusedVariables(log Logger, mod *Module, findings *[]ValidationFinding) {
+	for _, name := range mod.Variables {
+		if mod.VarRefs[name] {
+			continue
+		}
+		msg := "variable is declared but never referenced"
+		*findings = append(*findings, ValidationFinding{
+			ResourceType: "variable",
+			Path:         "root",
+			Name:         name,
+			Kind:         FindingInvalid,
+			Message:      msg,
+		})
+		log.Logf("variable %s: %s", name, msg)
+	}
+}
+
+// validateProviderVersions flags required_providers constraints that admit
+// versions outside the ranges in opts.ProviderVersionRanges.
+func validateProviderVersions(log Logger, providers map[string]ProviderConfig, opts *Options, findings *[]ValidationFinding) {
+	if opts == nil {
+		return
+	}
+	names := make([]string, 0, len(opts.ProviderVersionRanges))
+	for name := range opts.ProviderVersionRanges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		config, ok := providers[name]
+		if !ok || config.Version == "" {
+			continue
+		}
+		allowed := opts.ProviderVersionRanges[name]
+		allowedLo, allowedHi, err := versionInterval(allowed)
+		if err != nil {
+			log.Logf("Ignoring invalid version range %q for provider %s: %v", allowed, name, err)
+			continue
+		}
+
+		var msg string
+		lo, hi, err := versionInterval(config.Version)
+		switch {
+		case err != nil:
+			msg = fmt.Sprintf("invalid version constraint %q: %v", config.Version, err)
+		case lo.below(allowedLo):
+			msg = fmt.Sprintf("version constraint %q allows versions below %q", config.Version, allowed)
+		case hi.above(allowedHi):
+			msg = fmt.Sprintf("version constraint %q allows versions above %q", config.Version, allowed)
+		default:
+			continue
+		}
+		*findings = append(*findings, ValidationFinding{
+			ResourceType: "terraform.required_providers",
+			Path:         "root",
+			Name:         name,
+			Kind:         FindingInvalid,
+			Message:      msg,
+		})
+		log.Logf("provider %s: %s", name, msg)
+	}
+}
+
+// validateProviderPins flags required_providers entries that set no version
+// constraint, so any release of the provider would be accepted.
+func validateProviderPins(log Logger, providers map[string]ProviderConfig, opts *Options, findings *[]ValidationFinding) {
+	if opts == nil || !opts.RequireProviderVersions {
+		return
+	}
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if providers[name].Version != "" {
+			continue
+		}
+		*findings = append(*findings, ValidationFinding{
+			ResourceType: "terraform.required_providers",
+			Path:         "root",
+			Name:         name,
+			Kind:         FindingInvalid,
+			Message:      "no version constraint",
+		})
+		log.Logf("provider %s: no version constraint", name)
+	}
+}
+
+// validateVersionFile flags a .terraform-version or .tool-versions pin that
+// does not satisfy required_version.
+func validateVersionFile(log Logger, mod *Module, findings *[]ValidationFinding) {
+	if mod.RequiredVersion == "" || mod.PinnedVersion == "" {
+		return
+	}
+	constraints, err := version.NewConstraint(mod.RequiredVersion)
+	if err != nil {
+		log.Logf("Ignoring invalid required_version %q: %v", mod.RequiredVersion, err)
+		return
+	}
+
+	var msg string
+	if pinned, err := version.NewVersion(mod.PinnedVersion); err != nil {
+		msg = fmt.Sprintf("pinned version %q is not a version", mod.PinnedVersion)
+	} else if !constraints.Check(pinned) {
+		msg = fmt.Sprintf("pinned version %s does not satisfy required_version %q", mod.PinnedVersion, mod.RequiredVersion)
+	} else {
+		return
+	}
+	name := filepath.Base(mod.VersionFile)
+	*findings = append(*findings, ValidationFinding{
+		ResourceType: "terraform.required_version",
+		Path:         "root",
+		Name:         name,
+		Kind:         FindingInvalid,
+		Message:      msg,
+	})
+	log.Logf("%s: %s", name, msg)
+}
+
+// versionBound is one end of a version interval; a nil version is unbounded.
+type versionBound struct {
+	v         *version.Version
+	inclusive bool
+}
+
+// below reports whether lower bound b admits versions under lower bound min.
+func (b versionBound) below(min versionBound) bool {
+	if min.v == nil {
+		return false
+	}
+	if b.v == nil {
+		return true
+	}
+	return b.v.LessThan(min.v) || b.v.Equal(min.v) && b.inclusive && !min.inclusive
+}
+
+// above reports whether upper bound b admits versions over upper bound max.
+func (b versionBound) above(max versionBound) bool {
+	if max.v == nil {
+		return false
+	}
+	if b.v == nil {
+		return true
+	}
+	return b.v.GreaterThan(max.v) || b.v.Equal(max.v) && b.inclusive && !max.inclusive
+}
+
+var constraintPattern = regexp.MustCompile(`^(=|!=|>=|<=|>|<|~>)?\s*(\S+)$`)
+
+// versionInterval reduces a Terraform version constraint to the lowest and
+// highest bounds it admits. != exclusions are ignored since they do not move
+// the bounds.
+func versionInterval(constraint string) (lo, hi versionBound, err error) {
+	if _, err := version.NewConstraint(constraint); err != nil {
+		return lo, hi, err
+	}
+	for _, part := range strings.Split(constraint, ",") {
+		m := constraintPattern.FindStringSubmatch(strings.TrimSpace(part))
+		if m == nil {
+			return lo, hi, fmt.Errorf("malformed constraint %q", part)
+		}
+		v, err := version.NewVersion(m[2])
+		if err != nil {
+			return lo, hi, err
+		}
+
+		var partLo, partHi versionBound
+		switch m[1] {
+		case "", "=":
+			partLo, partHi = versionBound{v, true}, versionBound{v, true}
+		case ">=":
+			partLo = versionBound{v, true}
+		case ">":
+			partLo = versionBound{v, false}
+		case "<=":
+			partHi = versionBound{v, true}
+		case "<":
+			partHi = versionBound{v, false}
+		case "~>":
+			upper, err := pessimisticUpper(m[2], v)
+			if err != nil {
+				return lo, hi, err
+			}
+			partLo, partHi = versionBound{v, true}, versionBound{upper, false}
+		case "!=":
+			continue
+		}
+
+		if partLo.v != nil && (lo.v == nil || lo.below(partLo)) {
+			lo = partLo
+		}
+		if partHi.v != nil && (hi.v == nil || hi.above(partHi)) {
+			hi = partHi
+		}
+	}
+	return lo, hi, nil
+}
+
+// pessimisticUpper returns the exclusive upper bound of ~> raw: the segment
+// before the last one given is incremented, so ~> 4.1 is < 5.0 and ~> 4.1.2
+// is < 4.2.0. A single segment behaves like two.
+func pessimisticUpper(raw string, v *version.Version) (*version.Version, error) {
+	n := strings.Count(strings.SplitN(raw, "-", 2)[0], ".") + 1
+	segments := v.Segments()
+	i := n - 2
+	if i < 0 {
+		i = 0
+	}
+	parts := make([]string, i+1)
+	for j := 0; j < i; j++ {
+		parts[j] = strconv.Itoa(segments[j])
+	}
+	parts[i] = strconv.Itoa(segments[i] + 1)
+	return version.NewVersion(strings.Join(parts, "."))
+}
+
+// validateOutputReferences flags outputs whose value references a resource,
+// data source or module that is not declared.
+func validateOutputReferences(log Logger, outputs []ParsedOutput, declared map[string]bool, findings *[]ValidationFinding) {
+	for _, output := range outputs {
+		if output.Value == nil {
+			continue
+		}
+		for _, traversal := range output.Value.Variables() {
+			addr := referenceAddress(traversal)
+			if addr == "" || declared[addr] {
+				continue
+			}
+			msg := fmt.Sprintf("references undeclared %s", addr)
+			*findings = append(*findings, ValidationFinding{
+				ResourceType: "output",
+				Path:         "root",
+				Name:         output.Name,
+				Kind:         FindingInvalid,
+				Message:      msg,
+			})
+			log.Logf("output %s: %s", output.Name, msg)
+		}
+	}
+}
+
+// validateSensitiveSchemaOutputs flags outputs that reference an attribute the
+// provider schema marks sensitive without sensitive = true. Outputs already
+// flagged by validateSensitiveOutputs are left alone.
+func validateSensitiveSchemaOutputs(log Logger, mod *Module, schemas schemaIndex, findings *[]ValidationFinding) {
+	flagged := make(map[string]bool)
+	for _, f := range *findings {
+		if f.ResourceType == "output" && f.Message == sensitiveOutputMessage {
+			flagged[f.Name] = true
+		}
+	}
+
+	for _, output := range mod.Outputs {
+		if output.Sensitive || output.Value == nil || flagged[output.Name] {
+			continue
+		}
+		for _, traversal := range output.Value.Variables() {
+			res, attr := referencedResourceAttribute(mod.Resources, traversal)
+			if res == nil {
+				continue
+			}
+			providerName, _ := resourceProvider(*res)
+			schema, _ := schemas.resolveResourceSchema(discardLogger{}, mod.Providers, providerName, res.Label())
+			if schema == nil || schema.Block == nil || schema.Block.Attributes[attr] == nil || !schema.Block.Attributes[attr].Sensitive {
+				continue
+			}
+			msg := fmt.Sprintf("output exposes sensitive attribute %s.%s.%s but is not marked sensitive", res.Label(), res.Name, attr)
+			*findings = append(*findings, ValidationFinding{
+				ResourceType: "output",
+				Path:         "root",
+				Name:         output.Name,
+				Kind:         FindingInvalid,
+				Message:      msg,
+			})
+			log.Logf("output %s: %s", output.Name, msg)
+			break
+		}
+	}
+}
+
+// referencedResourceAttribute resolves type.name[index].attr, or
+// data.type.name[index].attr, to the declared resource and the attribute name.
+func referencedResourceAttribute(resources []ParsedResource, traversal hcl.Traversal) (*ParsedResource, string) {
+	kind, resType := KindResource, traversal.RootName()
+	if resType == "data" && len(traversal) > 1 {
+		typ, ok := traversal[1].(hcl.TraverseAttr)
+		if !ok {
+			return nil, ""
+		}
+		kind, resType = KindData, typ.Name
+		traversal = traversal[1:]
+	}
+	if len(traversal) < 3 {
+		return nil, ""
+	}
+	name, ok := traversal[1].(hcl.TraverseAttr)
+	if !ok {
+		return nil, ""
+	}
+	var attr string
+	for _, step := range traversal[2:] {
+		if a, ok := step.(hcl.TraverseAttr); ok {
+			attr = a.Name
+			break
+		}
+	}
+	if attr == "" {
+		return nil, ""
+	}
+	for i := range resources {
+		res := &resources[i]
+		if (res.Kind == KindData) == (kind == KindData) && res.Type == resType && res.Name == name.Name {
+			return res, attr
+		}
+	}
+	return nil, ""
+}
+
+// lastAttrName returns the final attribute name of a reference, so
+// azurerm_storage_account.x.primary_access_key yields primary_access_key.
+func lastAttrName(traversal hcl.Traversal) string {
+	for i := len(traversal) - 1; i > 0; i-- {
+		if attr, ok := traversal[i].(hcl.TraverseAttr); ok {
+			return attr.Name
+		}
+	}
+	return ""
+}
+
+// literalValue evaluates expr and reports whether it produced a known, non-null value.
+func literalValue(expr hclsyntax.Expression, ctx *hcl.EvalContext) (cty.Value, bool) {
+	val, diags := expr.Value(ctx)
+	if diags.HasErrors() || !val.IsWhollyKnown() || val.IsNull() {
+		return cty.NilVal, false
+	}
+	return val, true
+}
+
+// GitHub implementation
+type GitHubIssueService struct {
+	RepoOwner string
+	RepoName  string
+	BaseURL   string
+	Module    string
+	// PerFinding files a separate issue per distinct finding instead of
+	// one aggregated issue.
+	PerFinding bool
+	Sort       SortMode
+	// Formatter renders issue bodies; nil uses IssueBodyFormatter. Module
+	// sections always use the checkbox layout so they can be merged.
+	Formatter FindingFormatter
+	// Run, when set, is named at the end of every issue body.
+	Run     *RunContext
+	token   string
+	Client  *http.Client
+	Limiter RequestLimiter
+}
+
+// RequestLimiter bounds the number of in-flight API requests. A single
+// limiter is shared by every issue service in a run.
+type RequestLimiter chan struct{}
+
+func NewRequestLimiter(n int) RequestLimiter {
+	if n < 1 {
+		n = 1
+	}
+	return make(RequestLimiter, n)
+}
+
+// ghConcurrency reads GOPHX_GH_CONCURRENCY, defaulting to 2.
+func ghConcurrency() int {
+	if n, err := strconv.Atoi(os.Getenv("GOPHX_GH_CONCURRENCY")); err == nil && n > 0 {
+		return n
+	}
+	return 2
+}
+
+func (l RequestLimiter) Do(client *http.Client, req *http.Request) (*http.Response, error) {
+	if l != nil {
+		l <- struct{}{}
+		defer func() { <-l }()
+	}
+	return client.Do(req)
+}
+
+func (g *GitHubIssueService) apiURL(format string, args ...any) string {
+	base := g.BaseURL
+	if base == "" {
+		base = "https://api.github.com"
+	}
+	return strings.TrimSuffix(base, "/") + fmt.Sprintf(format, args...)
+}
+
+func (g *GitHubIssueService) CreateOrUpdateIssue(findings []ValidationFinding) error {
+	if len(findings) == 0 {
+		return nil
+	}
+
+	if g.PerFinding {
+		return g.createOrUpdatePerFinding(findings)
+	}
+
+	newBody, err := formatterOrDefault(g.Formatter, g.Sort).Format(findings)
+	if err != nil {
+		return fmt.Errorf("format issue body: %w", err)
+	}
+
+	title := "Generated schema validation"
+	existing, err := g.findExistingIssue(title)
+	if err != nil {
+		return err
+	}
+
+	var existingBody string
+	if existing != nil {
+		existingBody = existing.Body
+	}
+	finalBody := mergeIssueBody(existingBody, newBody, g.Module, findings, g.Sort, g.Run)
+
+	severity := severityLabel(findings)
+	if existing != nil {
+		return g.updateIssue(existing.Number, finalBody, reconcileLabels(labelNames(existing.Labels), severity))
+	}
+	return g.createIssue(title, finalBody, []string{severity})
+}
+
+// mergeIssueBody combines newBody with the body of an existing issue: a
+// module section replaces only that module's findings, otherwise text above
+// the findings header is kept. The run footer is replaced.
+func mergeIssueBody(existingBody, newBody, module string, findings []ValidationFinding, sortMode SortMode, run *RunContext) string {
+	existingBody = stripRunFooter(existingBody)
+
+	finalBody := newBody
+	if module != "" {
+		finalBody = mergeModuleSection(existingBody, module, formatModuleSection(module, findings, sortMode))
+	} else if strings.Contains(existingBody, issueHeader) {
+		existingParts := strings.SplitN(existingBody, issueHeader, 2)
+		if len(existingParts) > 0 {
+			finalBody = strings.TrimSpace(existingParts[0]) + "\n\n" + newBody
+		}
+	}
+	return withRunFooter(finalBody, run)
+}
+
+const (
+	labelSeverityRequired = "severity:required"
+	labelSeverityOptional = "severity:optional"
+)
+
+// severityLabel is severity:required when any finding is required.
+func severityLabel(findings []ValidationFinding) string {
+	for _, f := range findings {
+		if f.Required {
+			return labelSeverityRequired
+		}
+	}
+	return labelSeverityOptional
+}
+
+// reconcileLabels keeps non-severity labels and swaps in the current severity.
+func reconcileLabels(current []string, severity string) []string {
+	labels := make([]string, 0, len(current)+1)
+	for _, l := range current {
+		if l != labelSeverityRequired && l != labelSeverityOptional {
+			labels = append(labels, l)
+		}
+	}
+	return append(labels, severity)
+}
+
+func labelNames(labels []githubLabel) []string {
+	names := make([]string, len(labels))
+	for i, l := range labels {
+		names[i] = l.Name
+	}
+	return names
+}
+
+const issueHeader = "### \n\n"
+
+type NameCount struct {
+	Name  string
+	Count int
+}
+
+// TopMissing ranks attribute and block names by how often they are reported
+// missing, most frequent first and ties broken by name.
+func TopMissing(findings []ValidationFinding, n int) []NameCount {
+	counts := make(map[string]int)
+	for _, f := range findings {
+		if f.Kind == FindingMissing {
+			counts[f.Name]++
+		}
+	}
+
+	ranked := make([]NameCount, 0, len(counts))
+	for name, count := range counts {
+		ranked = append(ranked, NameCount{Name: name, Count: count})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Count != ranked[j].Count {
+			return ranked[i].Count > ranked[j].Count
+		}
+		return ranked[i].Name < ranked[j].Name
+	})
+
+	if n >= 0 && len(ranked) > n {
+		ranked = ranked[:n]
+	}
+	return ranked
+}
+
+// IssueBodyFormatter renders the default checkbox issue body.
+type IssueBodyFormatter struct {
+	Sort SortMode
+}
+
+func (f IssueBodyFormatter) Format(findings []ValidationFinding) (string, error) {
+	return formatIssueBody(findings, f.Sort), nil
+}
+
+// ListFormatter renders the line-per-finding FormatFindingsList output.
+type ListFormatter struct{}
+
+func (ListFormatter) Format(findings []ValidationFinding) (string, error) {
+	return FormatFindingsList(findings), nil
+}
+
+func formatterOrDefault(f FindingFormatter, mode SortMode) FindingFormatter {
+	if f == nil {
+		return IssueBodyFormatter{Sort: mode}
+	}
+	return f
+}
+
+// formatIssueBody renders deduplicated findings grouped by resource type and
+// path, with one checkbox per missing item.
+func formatIssueBody(findings []ValidationFinding, mode SortMode) string {
+	return issueHeader + formatFindingGroups(findings, mode)
+}
+
+func moduleMarkers(module string) (start, end string) {
+	return fmt.Sprintf("<!-- gophx:module:%s -->", module), fmt.Sprintf("<!-- /gophx:module:%s -->", module)
+}
+
+// formatModuleSection renders one module's findings between markers so the
+// section can later be replaced without touching other modules.
+func formatModuleSection(module string, findings []ValidationFinding, mode SortMode) string {
+	start, end := moduleMarkers(module)
+	return fmt.Sprintf("%s\n#### Module: %s\n\n%s%s\n", start, module, formatFindingGroups(findings, mode), end)
+}
+
+// mergeModuleSection replaces module's section in body, appending it when the
+// body has no section for that module yet.
+func mergeModuleSection(body, module, section string) string {
+	start, end := moduleMarkers(module)
+	i := strings.Index(body, start)
+	j := strings.Index(body, end)
+	if i >= 0 && j > i {
+		rest := strings.TrimPrefix(body[j+len(end):], "\n")
+		return body[:i] + section + rest
+	}
+	if strings.TrimSpace(body) == "" {
+		return issueHeader + section
+	}
+	return strings.TrimRight(body, "\n") + "\n\n" + section
+}
+
+// SortMode orders the items listed within each resource group.
+type SortMode string
+
+const (
+	SortAlpha         SortMode = "alpha"
+	SortRequiredFirst SortMode = "required-first"
+)
+
+// ParseSortMode maps a GOPHX_SORT value to a SortMode, defaulting to alpha.
+func ParseSortMode(v string) (SortMode, error) {
+	switch SortMode(v) {
+	case "", SortAlpha:
+		return SortAlpha, nil
+	case SortRequiredFirst:
+		return SortRequiredFirst, nil
+	}
+	return "", fmt.Errorf("unknown sort mode %q", v)
+}
+
+func formatFindingGroups(findings []ValidationFinding, mode SortMode) string {
+	type group struct {
+		resourceType string
+		path         string
+		items        map[string]ValidationFinding
+	}
+	groups := make(map[string]*group)
+
+	// Deduplicate findings
+	for _, f := range findings {
+		cleanPath := strings.ReplaceAll(f.Path, "root.", "")
+		groupKey := f.ResourceType + "|" + cleanPath
+		g := groups[groupKey]
+		if g == nil {
+			g = &group{resourceType: f.ResourceType, path: cleanPath, items: make(map[string]ValidationFinding)}
+			groups[groupKey] = g
+		}
+		g.items[fmt.Sprintf("%s|%v|%s|%s", f.Name, f.IsBlock, f.Kind, f.Message)] = f
+	}
+
+	groupKeys := make([]string, 0, len(groups))
+	for k := range groups {
+		groupKeys = append(groupKeys, k)
+	}
+	sort.Strings(groupKeys)
+
+	var body bytes.Buffer
+	for _, gk := range groupKeys {
+		g := groups[gk]
+		fmt.Fprintf(&body, "**Resource Type:** %s **Path:** %s\n", g.resourceType, g.path)
+
+		itemKeys := make([]string, 0, len(g.items))
+		for k := range g.items {
+			itemKeys = append(itemKeys, k)
+		}
+		sort.Slice(itemKeys, func(i, j int) bool {
+			a, b := g.items[itemKeys[i]], g.items[itemKeys[j]]
+			if mode == SortRequiredFirst && a.Required != b.Required {
+				return a.Required
+			}
+			return itemKeys[i] < itemKeys[j]
+		})
+
+		for _, ik := range itemKeys {
+			f := g.items[ik]
+			fmt.Fprintf(&body, "- [ ] %s (required: %v)", f.Name, f.Required)
+			if f.Message != "" {
+				fmt.Fprintf(&body, ": %s", f.Message)
+			}
+			fmt.Fprint(&body, "\n")
+		}
+		fmt.Fprint(&body, "\n")
+	}
+	return body.String()
+}
+
+type githubIssue struct {
+	Number int           `json:"number"`
+	Title  string        `json:"title"`
+	Body   string        `json:"body"`
+	Labels []githubLabel `json:"labels"`
+}
+
+type githubLabel struct {
+	Name string `json:"name"`
+}
+
+func (g *GitHubIssueService) findExistingIssue(title string) (*githubIssue, error) {
+	issues, err := g.listOpenIssues()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range issues {
+		if issues[i].Title == title {
+			return &issues[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// githubPageSize is the largest page the GitHub issues API returns.
+const githubPageSize = 100
+
+// listOpenIssues returns every open issue, following pages until one comes
+// back short.
+func (g *GitHubIssueService) listOpenIssues() ([]githubIssue, error) {
+	var issues []githubIssue
+	for page := 1; ; page++ {
+		batch, err := g.listOpenIssuesPage(page)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, batch...)
+		if len(batch) < githubPageSize {
+			return issues, nil
+		}
+	}
+}
+
+func (g *GitHubIssueService) listOpenIssuesPage(page int) ([]githubIssue, error) {
+	url := g.apiURL("/repos/%s/%s/issues?state=open&per_page=%d&page=%d", g.RepoOwner, g.RepoName, githubPageSize, page)
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set("Authorization", "token "+g.token)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+
+	resp, err := g.Limiter.Do(g.Client, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GitHub API error: %s", resp.Status)
+	}
+
+	var issues []githubIssue
+	if err := json.NewDecoder(resp.Body).Decode(&issues); err != nil {
+		return nil, err
+	}
+	return issues, nil
+}
+
+// createOrUpdatePerFinding files one issue per distinct finding title, with
+// the other findings of the same resource type listed as related items.
+func (g *GitHubIssueService) createOrUpdatePerFinding(findings []ValidationFinding) error {
+	issues, err := g.listOpenIssues()
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]githubIssue, len(issues))
 	for _, issue := range issues {
-		if issue.Title == title {
-			return issue.Number, issue.Body, nil
+		existing[issue.Title] = issue
+	}
+
+	byTitle := make(map[string][]ValidationFinding)
+	var titles []string
+	for _, f := range findings {
+		title := findingIssueTitle(f)
+		if _, ok := byTitle[title]; !ok {
+			titles = append(titles, title)
+		}
+		byTitle[title] = append(byTitle[title], f)
+	}
+	sort.Strings(titles)
+
+	for _, title := range titles {
+		group := byTitle[title]
+		var related []ValidationFinding
+		for _, f := range findings {
+			if f.ResourceType == group[0].ResourceType && findingIssueTitle(f) != title {
+				related = append(related, f)
+			}
+		}
+
+		body, err := formatterOrDefault(g.Formatter, g.Sort).Format(group)
+		if err != nil {
+			return fmt.Errorf("format issue body: %w", err)
+		}
+		if len(related) > 0 {
+			body += "Related missing items:\n\n" + formatFindingGroups(related, g.Sort)
+		}
+		body = withRunFooter(body, g.Run)
+
+		severity := severityLabel(group)
+		if issue, ok := existing[title]; ok {
+			err = g.updateIssue(issue.Number, body, reconcileLabels(labelNames(issue.Labels), severity))
+		} else {
+			err = g.createIssue(title, body, []string{severity})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func findingIssueTitle(f ValidationFinding) string {
+	action := "Invalid"
+	switch f.Kind {
+	case FindingMissing:
+		action = "Missing"
+	case FindingInfo:
+		action = "Review"
+	case FindingUnexpected:
+		action = "Unexpected"
+	}
+	return fmt.Sprintf("Terraform Validation: %s %s in %s", action, f.Name, f.ResourceType)
+}
+
+func (g *GitHubIssueService) updateIssue(issueNumber int, body string, labels []string) error {
+	url := g.apiURL("/repos/%s/%s/issues/%d", g.RepoOwner, g.RepoName, issueNumber)
+	payload := struct {
+		Body   string   `json:"body"`
+		Labels []string `json:"labels,omitempty"`
+	}{Body: body, Labels: labels}
+
+	jsonPayload, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("PATCH", url, bytes.NewReader(jsonPayload))
+	req.Header.Set("Authorization", "token "+g.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := g.Limiter.Do(g.Client, req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("GitHub API error: %s", resp.Status)
+	}
+	return nil
+}
+
+func (g *GitHubIssueService) createIssue(title, body string, labels []string) error {
+	payload := struct {
+		Title  string   `json:"title"`
+		Body   string   `json:"body"`
+		Labels []string `json:"labels,omitempty"`
+	}{
+		Title:  title,
+		Body:   body,
+		Labels: labels,
+	}
+
+	jsonPayload, _ := json.Marshal(payload)
+	url := g.apiURL("/repos/%s/%s/issues", g.RepoOwner, g.RepoName)
+	req, _ := http.NewRequest("POST", url, bytes.NewReader(jsonPayload))
+	req.Header.Set("Authorization", "token "+g.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := g.Limiter.Do(g.Client, req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("GitHub API error: %s", resp.Status)
+	}
+	return nil
+}
+
+// GitLab implementation
+type GitLabIssueService struct {
+	// ProjectPath is the full project path, e.g. "group/subgroup/project".
+	ProjectPath string
+	// BaseURL defaults to https://gitlab.com.
+	BaseURL   string
+	Module    string
+	Sort      SortMode
+	Formatter FindingFormatter
+	Run       *RunContext
+	token     string
+	Client    *http.Client
+	Limiter   RequestLimiter
+}
+
+type gitlabIssue struct {
+	IID         int      `json:"iid"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Labels      []string `json:"labels"`
+}
+
+func (g *GitLabIssueService) apiURL(format string, args ...any) string {
+	base := g.BaseURL
+	if base == "" {
+		base = "https://gitlab.com"
+	}
+	return fmt.Sprintf("%s/api/v4/projects/%s", strings.TrimSuffix(base, "/"), url.PathEscape(g.ProjectPath)) +
+		fmt.Sprintf(format, args...)
+}
+
+func (g *GitLabIssueService) CreateOrUpdateIssue(findings []ValidationFinding) error {
+	if len(findings) == 0 {
+		return nil
+	}
+
+	newBody, err := formatterOrDefault(g.Formatter, g.Sort).Format(findings)
+	if err != nil {
+		return fmt.Errorf("format issue body: %w", err)
+	}
+
+	title := "Generated schema validation"
+	existing, err := g.findExistingIssue(title)
+	if err != nil {
+		return err
+	}
+
+	var existingBody string
+	if existing != nil {
+		existingBody = existing.Description
+	}
+	description := mergeIssueBody(existingBody, newBody, g.Module, findings, g.Sort, g.Run)
+
+	severity := severityLabel(findings)
+	if existing != nil {
+		payload := struct {
+			Description string `json:"description"`
+			Labels      string `json:"labels"`
+		}{description, strings.Join(reconcileLabels(existing.Labels, severity), ",")}
+		return g.doJSON("PUT", g.apiURL("/issues/%d", existing.IID), payload, nil)
+	}
+	payload := struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Labels      string `json:"labels"`
+	}{title, description, severity}
+	return g.doJSON("POST", g.apiURL("/issues"), payload, nil)
+}
+
+// findExistingIssue returns the open issue whose title is exactly title;
+// the search parameter also matches partial titles.
+func (g *GitLabIssueService) findExistingIssue(title string) (*gitlabIssue, error) {
+	var issues []gitlabIssue
+	u := g.apiURL("/issues?state=opened&in=title&search=%s", url.QueryEscape(title))
+	if err := g.doJSON("GET", u, nil, &issues); err != nil {
+		return nil, err
+	}
+	for i := range issues {
+		if issues[i].Title == title {
+			return &issues[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (g *GitLabIssueService) doJSON(method, u string, payload, out any) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("PRIVATE-TOKEN", g.token)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := g.Limiter.Do(g.Client, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("GitLab API error: %s", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Azure DevOps implementation
+type AzureDevOpsIssueService struct {
+	Organization string
+	Project      string
+	BaseURL      string
+	// WorkItemType defaults to Issue.
+	WorkItemType string
+	Sort         SortMode
+	Formatter    FindingFormatter
+	Run          *RunContext
+	token        string
+	Client       *http.Client
+	Limiter      RequestLimiter
+}
+
+// adoMarker identifies the work item description owned by gophx.
+const adoMarker = "gophx:findings"
+
+// adoAPIVersion is the first version that accepts the Markdown format for
+// multiline fields.
+const adoAPIVersion = "7.1"
+
+type adoWorkItem struct {
+	ID     int `json:"id"`
+	Fields struct {
+		Title       string `json:"System.Title"`
+		Description string `json:"System.Description"`
+	} `json:"fields"`
+}
+
+type adoPatchOp struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value string `json:"value"`
+}
+
+func (a *AzureDevOpsIssueService) apiURL(format string, args ...any) string {
+	base := a.BaseURL
+	if base == "" {
+		base = "https://dev.azure.com"
+	}
+	return fmt.Sprintf("%s/%s/%s/_apis/wit", strings.TrimSuffix(base, "/"), url.PathEscape(a.Organization), url.PathEscape(a.Project)) +
+		fmt.Sprintf(format, args...)
+}
+
+func (a *AzureDevOpsIssueService) CreateOrUpdateIssue(findings []ValidationFinding) error {
+	if len(findings) == 0 {
+		return nil
+	}
+
+	title := "Generated schema validation"
+	body, err := formatterOrDefault(a.Formatter, a.Sort).Format(findings)
+	if err != nil {
+		return fmt.Errorf("format work item description: %w", err)
+	}
+	description := withRunFooter(fmt.Sprintf("<!-- %s -->\n\n%s", adoMarker, body), a.Run)
+	// The description is stored as Markdown so the checkboxes render as in
+	// the GitHub and GitLab issues.
+	format := adoPatchOp{Op: "add", Path: "/multilineFieldsFormat/System.Description", Value: "Markdown"}
+
+	existing, err := a.findExistingWorkItem(title)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return a.send("PATCH", a.apiURL("/workitems/%d?api-version=%s", existing.ID, adoAPIVersion), []adoPatchOp{
+			{Op: "add", Path: "/fields/System.Description", Value: description},
+			format,
+		})
+	}
+
+	itemType := a.WorkItemType
+	if itemType == "" {
+		itemType = "Issue"
+	}
+	return a.send("POST", a.apiURL("/workitems/$%s?api-version=%s", url.PathEscape(itemType), adoAPIVersion), []adoPatchOp{
+		{Op: "add", Path: "/fields/System.Title", Value: title},
+		{Op: "add", Path: "/fields/System.Description", Value: description},
+		format,
+	})
+}
+
+// findExistingWorkItem queries work items by title, skipping those in the
+// Completed or Removed state categories so a Done or Closed item is not
+// reopened, and returns the first whose description carries adoMarker.
+func (a *AzureDevOpsIssueService) findExistingWorkItem(title string) (*adoWorkItem, error) {
+	query := struct {
+		Query string `json:"query"`
+	}{Query: fmt.Sprintf("SELECT [System.Id] FROM WorkItems WHERE [System.TeamProject] = @project AND [System.Title] = '%s' AND [System.StateCategory] NOT IN ('Completed', 'Removed')",
+		strings.ReplaceAll(title, "'", "''"))}
+
+	var result struct {
+		WorkItems []struct {
+			ID int `json:"id"`
+		} `json:"workItems"`
+	}
+	if err := a.doJSON("POST", a.apiURL("/wiql?api-version=%s", adoAPIVersion), "application/json", query, &result); err != nil {
+		return nil, err
+	}
+	if len(result.WorkItems) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]string, len(result.WorkItems))
+	for i, item := range result.WorkItems {
+		ids[i] = strconv.Itoa(item.ID)
+	}
+	var items struct {
+		Value []adoWorkItem `json:"value"`
+	}
+	u := a.apiURL("/workitems?ids=%s&fields=System.Title,System.Description&api-version=%s", strings.Join(ids, ","), adoAPIVersion)
+	if err := a.doJSON("GET", u, "", nil, &items); err != nil {
+		return nil, err
+	}
+	for i := range items.Value {
+		if strings.Contains(items.Value[i].Fields.Description, adoMarker) {
+			return &items.Value[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (a *AzureDevOpsIssueService) send(method, u string, ops []adoPatchOp) error {
+	return a.doJSON(method, u, "application/json-patch+json", ops, nil)
+}
+
+func (a *AzureDevOpsIssueService) doJSON(method, u, contentType string, payload, out any) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth("", a.token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := a.Limiter.Do(a.Client, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Azure DevOps API error: %s", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Repository info implementation
+type GitRepoInfo struct {
+	terraformRoot string
+}
+
+func (g *GitRepoInfo) GetRepoInfo() (owner, name string) {
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+
+	if err := os.Chdir(g.terraformRoot); err != nil {
+		return "", ""
+	}
+
+	owner = os.Getenv("GITHUB_REPOSITORY_OWNER")
+	name = os.Getenv("GITHUB_REPOSITORY_NAME")
+	if owner != "" && name != "" {
+		return
+	}
+	owner = os.Getenv("CI_PROJECT_NAMESPACE")
+	name = os.Getenv("CI_PROJECT_NAME")
+	if owner != "" && name != "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), gitCommandTimeout)
+	defer cancel()
+	if out, err := exec.CommandContext(ctx, "git", "remote", "get-url", "origin").Output(); err == nil {
+		remote := strings.TrimSpace(string(out))
+		return parseGitRemote(remote)
+	}
+
+	if config, err := readFileLimited(".git/config", maxGitConfigSize); err == nil {
+		return parseGitConfig(string(config))
+	}
+
+	return "", ""
+}
+
+const (
+	gitCommandTimeout = 5 * time.Second
+	maxGitConfigSize  = 1 << 20
+)
+
+// readFileLimited reads at most max bytes from path and fails on larger files.
+func readFileLimited(path string, max int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > max {
+		return nil, fmt.Errorf("%s exceeds %d bytes", path, max)
+	}
+	return data, nil
+}
+
+// Findings sinks
+
+// FormatFindingsList renders one sorted, deduplicated line per finding:
+// "resource_type path name required block|property", with the kind appended
+// for findings other than missing ones.
+func FormatFindingsList(findings []ValidationFinding) string {
+	seen := make(map[string]bool, len(findings))
+	lines := make([]string, 0, len(findings))
+	for _, f := range findings {
+		itemType := "block"
+		if !f.IsBlock {
+			itemType = "property"
+		}
+		line := fmt.Sprintf("%s %s %s %v %s",
+			f.ResourceType,
+			strings.ReplaceAll(f.Path, "root.", ""),
+			f.Name,
+			f.Required,
+			itemType,
+		)
+		if f.Kind != FindingMissing {
+			line += " " + string(f.Kind)
+		}
+		if !seen[line] {
+			seen[line] = true
+			lines = append(lines, line)
+		}
+	}
+	sort.Strings(lines)
+
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// WriteFindingsFile writes FormatFindingsList output to path so it can be
+// committed with minimal diffs between runs.
+func WriteFindingsFile(path string, findings []ValidationFinding) error {
+	return os.WriteFile(path, []byte(FormatFindingsList(findings)), 0o644)
+}
+
+// findingJSON is the machine-readable form of a finding written by
+// WriteFindingsJSON.
+type findingJSON struct {
+	ResourceType string `json:"resource_type"`
+	Path         string `json:"path"`
+	Name         string `json:"name"`
+	Required     bool   `json:"required"`
+	IsBlock      bool   `json:"is_block"`
+	Kind         string `json:"kind"`
+	Message      string `json:"message,omitempty"`
+	Severity     string `json:"severity"`
+}
+
+// findingSeverity is required for required findings, info for lint-style
+// ones and optional otherwise, matching the issue severity labels.
+func findingSeverity(f ValidationFinding) string {
+	switch {
+	case f.Required:
+		return "required"
+	case f.Kind == FindingInfo:
+		return "info"
+	}
+	return "optional"
+}
+
+// WriteFindingsJSON writes findings to path as a JSON array for CI
+// dashboards, with paths relative to the resource.
+func WriteFindingsJSON(path string, findings []ValidationFinding) error {
+	out := make([]findingJSON, 0, len(findings))
+	for _, f := range findings {
+		out = append(out, findingJSON{
+			ResourceType: f.ResourceType,
+			Path:         strings.ReplaceAll(f.Path, "root.", ""),
+			Name:         f.Name,
+			Required:     f.Required,
+			IsBlock:      f.IsBlock,
+			Kind:         string(f.Kind),
+			Message:      f.Message,
+			Severity:     findingSeverity(f),
+		})
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Badge is a Shields.io endpoint payload.
+type Badge struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Label         string `json:"label"`
+	Message       string `json:"message"`
+	Color         string `json:"color"`
+}
+
+// NewBadge summarises findings as a badge: green with none, yellow with only
+// optional findings and red once any finding is required.
+func NewBadge(findings []ValidationFinding) Badge {
+	badge := Badge{SchemaVersion: 1, Label: "schema findings", Message: strconv.Itoa(len(findings)), Color: "green"}
+	for _, f := range findings {
+		if f.Required {
+			badge.Color = "red"
+			break
+		}
+		badge.Color = "yellow"
+	}
+	return badge
+}
+
+// WriteBadgeFile writes the NewBadge endpoint JSON to path.
+func WriteBadgeFile(path string, findings []ValidationFinding) error {
+	data, err := json.Marshal(NewBadge(findings))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// WritePromTextfile writes finding counts by resource type and severity as a
+// gophx_findings gauge in the Prometheus text format, for the node_exporter
+// textfile collector. The file is replaced atomically.
+func WritePromTextfile(path string, findings []ValidationFinding) error {
+	type series struct {
+		resourceType string
+		required     bool
+	}
+	counts := make(map[series]int)
+	for _, f := range findings {
+		counts[series{f.ResourceType, f.Required}]++
+	}
+	keys := make([]series, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].resourceType != keys[j].resourceType {
+			return keys[i].resourceType < keys[j].resourceType
+		}
+		return !keys[i].required && keys[j].required
+	})
+
+	var b strings.Builder
+	b.WriteString("# HELP gophx_findings Schema validation findings by resource type and severity.\n")
+	b.WriteString("# TYPE gophx_findings gauge\n")
+	labelEscaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "gophx_findings{resource_type=\"%s\",required=\"%t\"} %d\n", labelEscaper.Replace(k.resourceType), k.required, counts[k])
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// RecordFindings adds each finding as an event on a gophx.validate span,
+// tagged with run when it is set.
+func RecordFindings(ctx context.Context, tracer trace.Tracer, run *RunContext, findings []ValidationFinding) {
+	attrs := []attribute.KeyValue{attribute.Int("findings", len(findings))}
+	if run != nil {
+		attrs = append(attrs, attribute.String("run.id", run.ID), attribute.String("run.git_sha", run.GitSHA))
+	}
+	_, span := tracer.Start(ctx, "gophx.validate", trace.WithAttributes(attrs...), trace.WithTimestamp(run.startTime()))
+	defer span.End()
+
+	for _, f := range findings {
+		span.AddEvent("finding", trace.WithAttributes(
+			attribute.String("resource_type", f.ResourceType),
+			attribute.String("path", f.Path),
+			attribute.String("name", f.Name),
+			attribute.Bool("required", f.Required),
+			attribute.String("kind", string(f.Kind)),
+		))
+	}
+}
+
+// newOTLPTracerProvider exports spans over OTLP/HTTP, configured by the
+// standard OTEL_EXPORTER_OTLP_* variables.
+func newOTLPTracerProvider(ctx context.Context) (*sdktrace.TracerProvider, error) {
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("create OTLP exporter: %w", err)
+	}
+	return sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter)), nil
+}
+
+// RunContext identifies one validation run so that the issues, spans and
+// logs it produces can be correlated.
+type RunContext struct {
+	ID        string
+	GitSHA    string
+	StartTime time.Time
+}
+
+// NewRunContext starts a run for the repository at root. The ID joins the
+// UTC start time, the short HEAD SHA when root is a git checkout and a
+// random suffix.
+func NewRunContext(root string) *RunContext {
+	run := &RunContext{GitSHA: gitShortSHA(root), StartTime: time.Now().UTC()}
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+
+	parts := []string{run.StartTime.Format("20060102T150405Z")}
+	if run.GitSHA != "" {
+		parts = append(parts, run.GitSHA)
+	}
+	run.ID = strings.Join(append(parts, hex.EncodeToString(suffix)), "-")
+	return run
+}
+
+func (r *RunContext) startTime() time.Time {
+	if r == nil {
+		return time.Now()
+	}
+	return r.StartTime
+}
+
+func (r *RunContext) footer() string {
+	return runFooterPrefix + r.ID
+}
+
+func gitShortSHA(root string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), gitCommandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", "--short", "HEAD")
+	cmd.Dir = root
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// runFooterPrefix starts the line naming the run in issue bodies.
+const runFooterPrefix = "gophx run "
+
+// stripRunFooter drops the run line left in body by a previous run.
+func stripRunFooter(body string) string {
+	lines := strings.Split(body, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if !strings.HasPrefix(line, runFooterPrefix) {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// withRunFooter ends body with the line naming run, replacing any older one.
+func withRunFooter(body string, run *RunContext) string {
+	if run == nil {
+		return body
+	}
+	return strings.TrimRight(stripRunFooter(body), "\n") + "\n\n" + run.footer() + "\n"
+}
+
+// Terraform CLI helpers
+var ErrEmptySchema = errors.New("terraform returned no provider schemas")
+
+// terraformBinary returns TERRAFORM_BIN, falling back to terraform on PATH.
+func terraformBinary() string {
+	if bin := os.Getenv("TERRAFORM_BIN"); bin != "" {
+		return bin
+	}
+	return "terraform"
+}
+
+// checkFormatting runs terraform fmt -check and returns a finding per file
+// that would be reformatted. Diffs are logged since they do not fit a
+// finding line.
+func checkFormatting(log Logger, root string) ([]ValidationFinding, error) {
+	cmd := exec.CommandContext(context.Background(), terraformBinary(), "fmt", "-check", "-list=true", "-no-color")
+	cmd.Dir = root
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if err != nil && (!errors.As(err, &exitErr) || len(bytes.TrimSpace(out)) == 0) {
+		return nil, fmt.Errorf("terraform fmt failed: %w", err)
+	}
+
+	var findings []ValidationFinding
+	for _, file := range strings.Split(string(out), "\n") {
+		if file = strings.TrimSpace(file); file == "" {
+			continue
+		}
+		findings = append(findings, ValidationFinding{
+			ResourceType: "fmt",
+			Path:         "root",
+			Name:         file,
+			Kind:         FindingInvalid,
+			Message:      "file is not terraform fmt clean",
+		})
+
+		diff := exec.CommandContext(context.Background(), terraformBinary(), "fmt", "-check", "-diff", "-no-color", file)
+		diff.Dir = root
+		patch, _ := diff.Output()
+		log.Logf("%s is not terraform fmt clean\n%s", file, patch)
+	}
+	return findings, nil
+}
+
+func terraformInit(root string, args ...string) error {
+	cmd := exec.CommandContext(context.Background(), terraformBinary(), append([]string{"init"}, args...)...)
+	cmd.Dir = root
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("terraform init failed: %v\nOutput: %s", err, string(out))
+	}
+	return nil
+}
+
+func fetchSchema(root string) (*TerraformSchema, error) {
+	cmd := exec.CommandContext(context.Background(), terraformBinary(), "providers", "schema", "-json")
+	cmd.Dir = root
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	return DecodeSchema(out)
+}
+
+// LoadSchemaFile reads a cached `terraform providers schema -json` output.
+func LoadSchemaFile(path string) (*TerraformSchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read schema file: %w", err)
+	}
+	return DecodeSchema(data)
+}
+
+// providersFromSchema derives local provider names from schema source keys,
+// e.g. registry.terraform.io/hashicorp/azurerm becomes azurerm.
+func providersFromSchema(tfSchema *TerraformSchema) map[string]ProviderConfig {
+	providers := make(map[string]ProviderConfig, len(tfSchema.ProviderSchemas))
+	for source := range tfSchema.ProviderSchemas {
+		name := source[strings.LastIndex(source, "/")+1:]
+		providers[name] = ProviderConfig{Source: source}
+	}
+	return providers
+}
+
+// DecodeSchema parses `terraform providers schema -json` output. Empty output
+// or a result without providers yields ErrEmptySchema, since validating
+// against it would silently skip every resource.
+func DecodeSchema(data []byte) (*TerraformSchema, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, ErrEmptySchema
+	}
+
+	var schema TerraformSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("decode schema: %w", err)
+	}
+	if len(schema.ProviderSchemas) == 0 {
+		return nil, ErrEmptySchema
+	}
+	return &schema, nil
+}
+
+// Helper functions
+
+// lookupProviderSchema finds the schema for source, falling back to a
+// case-insensitive match. It returns the key that matched.
+func lookupProviderSchema(schemas map[string]*ProviderSchema, source string) (*ProviderSchema, string) {
+	if schema, ok := schemas[source]; ok {
+		return schema, source
+	}
+	for key, schema := range schemas {
+		if strings.EqualFold(key, source) {
+			return schema, key
+		}
+	}
+	return nil, ""
+}
+
+func normalizeSource(source string) string {
+	if strings.Contains(source, "/") && !strings.Contains(source, "registry.terraform.io/") {
+		return fmt.Sprintf("registry.terraform.io/%s", source)
+	}
+	return source
+}
+
+func extractIgnoreChanges(val cty.Value) []string {
+	var changes []string
+	if val.Type().IsCollectionType() {
+		for it := val.ElementIterator(); it.Next(); {
+			_, v := it.Element()
+			if v.Type() == cty.String {
+				changes = append(changes, v.AsString())
+			}
+		}
+	}
+	return changes
+}
+
+func findContentBlock(body *hclsyntax.Body) (*hclsyntax.Body, bool) {
+	for _, b := range body.Blocks {
+		if b.Type == "content" {
+			return b.Body, true
+		}
+	}
+	return nil, false
+}
+
+func mergeBlocks(dest, src *ParsedBlock) {
+	for k := range src.data.properties {
+		dest.data.properties[k] = true
+	}
+	for k, v := range src.data.attributes {
+		dest.data.attributes[k] = v
+	}
+	for k, v := range src.data.staticBlocks {
+		if existing, exists := dest.data.staticBlocks[k]; exists {
+			mergeBlocks(existing, v)
+		} else {
+			dest.data.staticBlocks[k] = v
+		}
+	}
+	for k, v := range src.data.dynamicBlocks {
+		if existing, exists := dest.data.dynamicBlocks[k]; exists {
+			mergeBlocks(existing, v)
+		} else {
+			dest.data.dynamicBlocks[k] = v
+		}
+	}
+	maxBlockCounts(&dest.data, &src.data)
+	dest.data.ignoreChanges = append(dest.data.ignoreChanges, src.data.ignoreChanges...)
+	for k, v := range src.data.forEach {
+		dest.data.forEach[k] = append(dest.data.forEach[k], v...)
+	}
+	for k, v := range src.data.staleIterators {
+		dest.data.staleIterators[k] = v
+	}
+	for _, name := range src.data.missingContent {
+		if !contains(dest.data.missingContent, name) {
+			dest.data.missingContent = append(dest.data.missingContent, name)
+		}
+	}
+}
+
+// maxBlockCounts keeps, at every depth shared by dst and src, the larger
+// block count, since item bounds apply to each parent block separately.
+func maxBlockCounts(dst, src *BlockData) {
+	for name, n := range src.blockCounts {
+		if n > dst.blockCounts[name] {
+			dst.blockCounts[name] = n
+		}
+	}
+	for name, block := range src.staticBlocks {
+		if existing := dst.staticBlocks[name]; existing != nil && existing != block {
+			maxBlockCounts(&existing.data, &block.data)
+		}
+	}
+}
+
+func logMissingAttribute(log Logger, resType, name, path string, required bool) {
+	status := "optional"
+	if required {
+		status = "required"
+	}
+	cleanPath := strings.ReplaceAll(path, "root.", "")
+	log.Logf("%s missing %s property %s in %s", resType, status, name, cleanPath)
+}
+
+func logMissingBlock(log Logger, resType, name, path string, required bool) {
+	status := "optional"
+	if required {
+		status = "required"
+	}
+	cleanPath := strings.ReplaceAll(path, "root.", "")
+	log.Logf("%s missing %s block %s in %s", resType, status, name, cleanPath)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// parseGitRemote returns the owner and repository name of an https or scp
+// style remote. On GitLab hosts the owner is the full group path, since
+// projects can sit in nested subgroups.
+func parseGitRemote(remote string) (string, string) {
+	var host string
+	var repoParts []string
+	if strings.HasPrefix(remote, "https://") {
+		parts := strings.Split(remote, "/")
+		if len(parts) >= 5 {
+			host, repoParts = parts[2], parts[3:]
+		}
+	}
+
+	if strings.HasPrefix(remote, "git@") {
+		parts := strings.Split(remote, ":")
+		if len(parts) == 2 {
+			host, repoParts = strings.TrimPrefix(parts[0], "git@"), strings.Split(parts[1], "/")
+		}
+	}
+
+	if len(repoParts) < 2 {
+		return "", ""
+	}
+	if isGitLabHost(host) {
+		n := len(repoParts)
+		return strings.Join(repoParts[:n-1], "/"), strings.TrimSuffix(repoParts[n-1], ".git")
+	}
+	return repoParts[0], strings.TrimSuffix(repoParts[1], ".git")
+}
+
+// isGitLabHost reports whether host looks like gitlab.com or a self-managed
+// GitLab instance.
+func isGitLabHost(host string) bool {
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+	return strings.Contains(strings.ToLower(host), "gitlab")
+}
+
+func parseGitConfig(config string) (string, string) {
+	inOrigin := false
+	for _, line := range strings.Split(config, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			section := strings.Join(strings.Fields(strings.Trim(line, "[]")), " ")
+			inOrigin = section == `remote "origin"`
+			continue
+		}
+		if !inOrigin {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if ok && strings.TrimSpace(key) == "url" {
+			return parseGitRemote(strings.TrimSpace(value))
+		}
+	}
+	return "", ""
+}
+
+func ParseSyntaxBody(body *hclsyntax.Body) *ParsedBlock {
+	bd := NewBlockData()
+	block := &ParsedBlock{data: bd}
+	block.data.ParseAttributes(body)
+	block.data.ParseBlocks(body)
+	return block
+}
+
+// Validation runner
+type Result struct {
+	Findings  []ValidationFinding
+	Providers int
+	Resources int
+	Validated int
+	// Skipped counts resources that were not validated, by reason.
+	Skipped map[SkipReason]int
+}
+
+// SkipReason explains why a resource was not validated.
+type SkipReason string
+
+const (
+	SkipExcluded     SkipReason = "excluded"
+	SkipNoProvider   SkipReason = "no provider"
+	SkipNoSchema     SkipReason = "no schema"
+	SkipComputedOnly SkipReason = "computed-only"
+)
+
+// Module is the parsed, schema-independent view of a Terraform module.
+type Module struct {
+	Providers      map[string]ProviderConfig
+	ProviderBlocks []ParsedProvider
+	Resources      []ParsedResource
+	Outputs        []ParsedOutput
+	Declared       map[string]bool
+	Vars           map[string]cty.Value
+	// Cloud is the terraform { cloud {} } block, if any.
+	Cloud *ParsedBlock
+	Moved []ParsedMoved
+	// Variables lists the declared variables and VarRefs the ones referenced,
+	// across every .tf file in the module.
+	Variables []string
+	VarRefs   map[string]bool
+	Locals    map[string]hclsyntax.Expression
+	// RequiredVersion is the terraform required_version constraint, and
+	// PinnedVersion the version read from VersionFile, if one was found.
+	RequiredVersion string
+	VersionFile     string
+	PinnedVersion   string
+	// Calls are the module blocks, with local children parsed recursively.
+	Calls []ParsedModuleCall
+}
+
+// ParseModule parses every .tf file and an optional terraform.tfvars in
+// root. main.tf must exist.
+func ParseModule(parser HCLParser, root string) (*Module, error) {
+	mainTfPath := filepath.Join(root, "main.tf")
+
+	if _, err := os.Stat(mainTfPath); err != nil {
+		return nil, fmt.Errorf("no main.tf found at %s: %w", mainTfPath, err)
+	}
+
+	resources, err := parser.ParseModuleFiles(root)
+	if err != nil {
+		return nil, fmt.Errorf("parse resources: %w", err)
+	}
+
+	versionFile, pinnedVersion, err := findVersionFile(root)
+	if err != nil {
+		return nil, fmt.Errorf("read terraform version file: %w", err)
+	}
+
+	tfFiles, err := filepath.Glob(filepath.Join(root, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+	providers := make(map[string]ProviderConfig)
+	var providerBlocks []ParsedProvider
+	var cloud *ParsedBlock
+	var requiredVersion string
+	var outputs []ParsedOutput
+	var moved []ParsedMoved
+	var variables []string
+	var calls []ParsedModuleCall
+	declared := make(map[string]bool)
+	varRefs := make(map[string]bool)
+	locals := make(map[string]hclsyntax.Expression)
+	for _, path := range tfFiles {
+		requirements, err := parser.ParseProviderRequirements(path)
+		if err != nil {
+			return nil, fmt.Errorf("parse provider config in %s: %w", filepath.Base(path), err)
+		}
+		for name, config := range requirements {
+			providers[name] = config
+		}
+
+		blocks, err := parser.ParseProviderBlocks(path)
+		if err != nil {
+			return nil, fmt.Errorf("parse provider blocks in %s: %w", filepath.Base(path), err)
+		}
+		providerBlocks = append(providerBlocks, blocks...)
+
+		if cloud == nil {
+			if cloud, err = parser.ParseCloudBlock(path); err != nil {
+				return nil, fmt.Errorf("parse cloud block in %s: %w", filepath.Base(path), err)
+			}
+		}
+		if requiredVersion == "" {
+			if requiredVersion, err = parser.ParseRequiredVersion(path); err != nil {
+				return nil, fmt.Errorf("parse required_version in %s: %w", filepath.Base(path), err)
+			}
+		}
+
+		fileOutputs, err := parser.ParseOutputs(path)
+		if err != nil {
+			return nil, fmt.Errorf("parse outputs in %s: %w", filepath.Base(path), err)
+		}
+		outputs = append(outputs, fileOutputs...)
+
+		fileMoved, err := parser.ParseMovedBlocks(path)
+		if err != nil {
+			return nil, fmt.Errorf("parse moved blocks in %s: %w", filepath.Base(path), err)
+		}
+		moved = append(moved, fileMoved...)
+
+		addresses, err := parser.ParseDeclaredAddresses(path)
+		if err != nil {
+			return nil, fmt.Errorf("parse declared addresses in %s: %w", filepath.Base(path), err)
+		}
+		for addr := range addresses {
+			declared[addr] = true
+		}
+
+		fileCalls, err := parser.ParseModuleCalls(path)
+		if err != nil {
+			return nil, fmt.Errorf("parse module calls in %s: %w", filepath.Base(path), err)
+		}
+		calls = append(calls, fileCalls...)
+
+		names, err := parser.ParseVariables(path)
+		if err != nil {
+			return nil, fmt.Errorf("parse variables in %s: %w", filepath.Base(path), err)
 		}
+		variables = append(variables, names...)
+
+		refs, err := parser.ParseVariableReferences(path)
+		if err != nil {
+			return nil, fmt.Errorf("parse variable references in %s: %w", filepath.Base(path), err)
+		}
+		for name := range refs {
+			varRefs[name] = true
+		}
+
+		fileLocals, err := parser.ParseLocals(path)
+		if err != nil {
+			return nil, fmt.Errorf("parse locals in %s: %w", filepath.Base(path), err)
+		}
+		for name, expr := range fileLocals {
+			locals[name] = expr
+		}
+	}
+
+	if err := parseChildModules(parser, root, calls, nil); err != nil {
+		return nil, err
+	}
+
+	vars := map[string]cty.Value{}
+	tfvarsPath := filepath.Join(root, "terraform.tfvars")
+	if _, err := os.Stat(tfvarsPath); err == nil {
+		if vars, err = parser.ParseTfvars(tfvarsPath); err != nil {
+			return nil, fmt.Errorf("parse terraform.tfvars: %w", err)
+		}
+	}
+
+	return &Module{
+		Providers:       providers,
+		ProviderBlocks:  providerBlocks,
+		Resources:       resources,
+		Outputs:         outputs,
+		Declared:        declared,
+		Vars:            vars,
+		Cloud:           cloud,
+		Moved:           moved,
+		Variables:       variables,
+		VarRefs:         varRefs,
+		Locals:          locals,
+		RequiredVersion: requiredVersion,
+		VersionFile:     versionFile,
+		PinnedVersion:   pinnedVersion,
+		Calls:           calls,
+	}, nil
+}
+
+// parseChildModules parses the local modules among calls, relative to dir,
+// and their own local modules in turn. visiting holds the directories on the
+// current call chain so a module that calls itself is reported, not followed.
+func parseChildModules(parser HCLParser, dir string, calls []ParsedModuleCall, visiting map[string]bool) error {
+	if visiting == nil {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		visiting = map[string]bool{abs: true}
+	}
+	for i := range calls {
+		call := &calls[i]
+		if !call.Local() {
+			continue
+		}
+		childDir, err := filepath.Abs(filepath.Join(dir, call.Source))
+		if err != nil {
+			return err
+		}
+		if visiting[childDir] {
+			return fmt.Errorf("module %s: %s calls itself", call.Name, call.Source)
+		}
+
+		child, err := parseChildModule(parser, childDir)
+		if err != nil {
+			return fmt.Errorf("module %s: %w", call.Name, err)
+		}
+		if child == nil {
+			continue
+		}
+		visiting[childDir] = true
+		err = parseChildModules(parser, childDir, child.Calls, visiting)
+		delete(visiting, childDir)
+		if err != nil {
+			return fmt.Errorf("module %s: %w", call.Name, err)
+		}
+		call.Module = child
+	}
+	return nil
+}
+
+// parseChildModule parses the resources, declared addresses, locals and
+// module calls of a called module, or returns nil when dir holds no .tf
+// files. Providers and variables come from the caller, so they are not read.
+func parseChildModule(parser HCLParser, dir string) (*Module, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	resources, err := parser.ParseModuleFiles(dir)
+	if err != nil {
+		return nil, fmt.Errorf("parse resources: %w", err)
 	}
-	return 0, "", nil
-}
 
-func (g *GitHubIssueService) updateIssue(issueNumber int, body string) error {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/issues/%d", g.RepoOwner, g.RepoName, issueNumber)
-	payload := struct {
-		Body string `json:"body"`
-	}{Body: body}
+	child := &Module{Resources: resources, Declared: make(map[string]bool), Locals: make(map[string]hclsyntax.Expression)}
+	for _, path := range paths {
+		addresses, err := parser.ParseDeclaredAddresses(path)
+		if err != nil {
+			return nil, fmt.Errorf("parse declared addresses in %s: %w", filepath.Base(path), err)
+		}
+		for addr := range addresses {
+			child.Declared[addr] = true
+		}
 
-	jsonPayload, _ := json.Marshal(payload)
-	req, _ := http.NewRequest("PATCH", url, bytes.NewReader(jsonPayload))
-	req.Header.Set("Authorization", "token "+g.token)
-	req.Header.Set("Content-Type", "application/json")
+		fileLocals, err := parser.ParseLocals(path)
+		if err != nil {
+			return nil, fmt.Errorf("parse locals in %s: %w", filepath.Base(path), err)
+		}
+		for name, expr := range fileLocals {
+			child.Locals[name] = expr
+		}
 
-	resp, err := g.Client.Do(req)
-	if err != nil {
-		return err
+		calls, err := parser.ParseModuleCalls(path)
+		if err != nil {
+			return nil, fmt.Errorf("parse module calls in %s: %w", filepath.Base(path), err)
+		}
+		child.Calls = append(child.Calls, calls...)
 	}
-	resp.Body.Close()
-	return nil
+	return child, nil
 }
 
-func (g *GitHubIssueService) createIssue(title, body string) error {
-	payload := struct {
-		Title string `json:"title"`
-		Body  string `json:"body"`
-	}{
-		Title: title,
-		Body:  body,
+// findVersionFile looks for a .terraform-version (tfenv) or .tool-versions
+// (asdf) file in root and its parents, stopping at the repository root, and
+// returns its path and the Terraform version it pins.
+func findVersionFile(root string) (path, pinned string, err error) {
+	dir, err := filepath.Abs(root)
+	if err != nil {
+		return "", "", err
 	}
+	for {
+		path = filepath.Join(dir, ".terraform-version")
+		if data, err := os.ReadFile(path); err == nil {
+			return path, strings.TrimSpace(strings.SplitN(string(data), "\n", 2)[0]), nil
+		}
+		path = filepath.Join(dir, ".tool-versions")
+		if data, err := os.ReadFile(path); err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == "terraform" {
+					return path, fields[1], nil
+				}
+			}
+		}
 
-	jsonPayload, _ := json.Marshal(payload)
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/issues", g.RepoOwner, g.RepoName)
-	req, _ := http.NewRequest("POST", url, bytes.NewReader(jsonPayload))
-	req.Header.Set("Authorization", "token "+g.token)
-	req.Header.Set("Content-Type", "application/json")
+		parent := filepath.Dir(dir)
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil || parent == dir {
+			return "", "", nil
+		}
+		dir = parent
+	}
+}
 
-	resp, err := g.Client.Do(req)
+// RunValidation validates the Terraform module in root against the schemas of
+// its required providers and returns the findings with run metadata.
+func RunValidation(root string, opts Options) (Result, error) {
+	mod, err := ParseModule(&DefaultHCLParser{}, root)
 	if err != nil {
-		return err
+		return Result{}, err
 	}
-	resp.Body.Close()
-	return nil
-}
 
-// Repository info implementation
-type GitRepoInfo struct {
-	terraformRoot string
+	tfSchema, err := loadSchema(root, &opts, mod.Cloud != nil)
+	if err != nil {
+		return Result{}, err
+	}
+	result := ValidateModule(mod, tfSchema, opts)
+
+	if opts.FmtCheck && !opts.RequiredOnly {
+		unformatted, err := checkFormatting(opts.logger(), root)
+		if err != nil {
+			return Result{}, err
+		}
+		result.Findings = append(result.Findings, unformatted...)
+	}
+	return result, nil
 }
 
-func (g *GitRepoInfo) GetRepoInfo() (owner, name string) {
-	originalDir, _ := os.Getwd()
-	defer os.Chdir(originalDir)
+// ValidateInput validates a single HCL file, or stdin when path is "-",
+// against the offline schema in opts.SchemaFile. Providers are inferred from
+// the schema since a snippet has no required_providers block.
+func ValidateInput(path string, stdin io.Reader, opts Options) (Result, error) {
+	if opts.SchemaFile == "" {
+		return Result{}, fmt.Errorf("validating a single input requires a schema file")
+	}
+	tfSchema, err := LoadSchemaFile(opts.SchemaFile)
+	if err != nil {
+		return Result{}, err
+	}
 
-	if err := os.Chdir(g.terraformRoot); err != nil {
-		return "", ""
+	resources, err := (&DefaultHCLParser{}).ParseInput(path, stdin)
+	if err != nil {
+		return Result{}, err
 	}
 
-	owner = os.Getenv("GITHUB_REPOSITORY_OWNER")
-	name = os.Getenv("GITHUB_REPOSITORY_NAME")
-	if owner != "" && name != "" {
-		return
+	mod := &Module{
+		Providers: providersFromSchema(tfSchema),
+		Resources: resources,
 	}
+	return ValidateModule(mod, tfSchema, opts), nil
+}
 
-	if out, err := exec.Command("git", "remote", "get-url", "origin").Output(); err == nil {
-		remote := strings.TrimSpace(string(out))
-		return parseGitRemote(remote)
+// loadSchema reads the offline schema when configured and otherwise runs
+// terraform in root, cleaning up the files init leaves behind. A cloud block
+// would make init contact Terraform Cloud, so it is skipped with
+// -backend=false; provider schemas do not depend on the backend.
+func loadSchema(root string, opts *Options, cloud bool) (*TerraformSchema, error) {
+	if opts.SchemaFile != "" {
+		return LoadSchemaFile(opts.SchemaFile)
 	}
+	log := opts.logger()
 
-	if config, err := os.ReadFile(".git/config"); err == nil {
-		return parseGitConfig(string(config))
+	var initArgs []string
+	if cloud {
+		log.Logf("Module declares a cloud block, initializing without backend")
+		initArgs = append(initArgs, "-backend=false")
 	}
 
-	return "", ""
-}
+	// Cleanup previous Terraform files
+	defer func() {
+		os.RemoveAll(filepath.Join(root, ".terraform"))
+		os.Remove(filepath.Join(root, "terraform.tfstate"))
+		os.Remove(filepath.Join(root, ".terraform.lock.hcl"))
+	}()
 
-// Helper functions
-func normalizeSource(source string) string {
-	if strings.Contains(source, "/") && !strings.Contains(source, "registry.terraform.io/") {
-		return fmt.Sprintf("registry.terraform.io/%s", source)
+	if err := terraformInit(root, initArgs...); err != nil {
+		return nil, err
 	}
-	return source
+
+	tfSchema, err := fetchSchema(root)
+	if errors.Is(err, ErrEmptySchema) {
+		log.Logf("Provider schema output was empty, retrying terraform init")
+		if err := terraformInit(root, initArgs...); err != nil {
+			return nil, err
+		}
+		tfSchema, err = fetchSchema(root)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get schema: %w", err)
+	}
+	return tfSchema, nil
 }
 
-func extractIgnoreChanges(val cty.Value) []string {
-	var changes []string
-	if val.Type().IsCollectionType() {
-		for it := val.ElementIterator(); it.Next(); {
-			_, v := it.Element()
-			if v.Type() == cty.String {
-				changes = append(changes, v.AsString())
+// resourceProvider returns the provider local name and alias a resource is
+// bound to, honouring the provider meta-argument before the type prefix.
+func resourceProvider(res ParsedResource) (name, alias string) {
+	if attr := res.data.attributes["provider"]; attr != nil {
+		if traversal, diags := hcl.AbsTraversalForExpr(attr.Expr); !diags.HasErrors() {
+			name = traversal.RootName()
+			if len(traversal) > 1 {
+				if step, ok := traversal[1].(hcl.TraverseAttr); ok {
+					alias = step.Name
+				}
 			}
+			return name, alias
 		}
 	}
-	return changes
+	return strings.SplitN(res.Type, "_", 2)[0], ""
 }
 
-func findContentBlock(body *hclsyntax.Body) *hclsyntax.Body {
-	for _, b := range body.Blocks {
-		if b.Type == "content" {
-			return b.Body
+// schemaIndex maps each resource type, and each data source as data.<type>,
+// to the provider schemas defining it, ordered by provider source.
+type schemaIndex map[string][]indexedSchema
+
+type indexedSchema struct {
+	source string
+	schema *ResourceSchema
+}
+
+// newSchemaIndex indexes the resource schemas of every provider in tfSchema.
+func newSchemaIndex(tfSchema *TerraformSchema) schemaIndex {
+	sources := make([]string, 0, len(tfSchema.ProviderSchemas))
+	for source := range tfSchema.ProviderSchemas {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	idx := make(schemaIndex)
+	for _, source := range sources {
+		providerSchema := tfSchema.ProviderSchemas[source]
+		if providerSchema == nil {
+			continue
+		}
+		for resType, schema := range providerSchema.ResourceSchemas {
+			if schema != nil {
+				idx[resType] = append(idx[resType], indexedSchema{source, schema})
+			}
+		}
+		for dataType, schema := range providerSchema.DataSourceSchemas {
+			if schema != nil {
+				key := "data." + dataType
+				idx[key] = append(idx[key], indexedSchema{source, schema})
+			}
 		}
 	}
-	return body
+	return idx
 }
 
-func mergeBlocks(dest, src *ParsedBlock) {
-	for k := range src.data.properties {
-		dest.data.properties[k] = true
-	}
-	for k, v := range src.data.staticBlocks {
-		if existing, exists := dest.data.staticBlocks[k]; exists {
-			mergeBlocks(existing, v)
-		} else {
-			dest.data.staticBlocks[k] = v
+// resolveResourceSchema routes resType to the provider schema that defines
+// it. When several do, the one the resource is bound to wins, then the first
+// by source, so a local name that differs from the type prefix (kube for
+// kubernetes_manifest) still resolves.
+func (idx schemaIndex) resolveResourceSchema(log Logger, providers map[string]ProviderConfig, providerName, resType string) (*ResourceSchema, SkipReason) {
+	config, bound := providers[providerName]
+	candidates := idx[resType]
+	if len(candidates) == 0 {
+		if !bound {
+			log.Logf("No provider configured for resource type %s", resType)
+			return nil, SkipNoProvider
 		}
+		log.Logf("No schema found for resource type %s in provider %s (%s)", resType, providerName, config.Source)
+		return nil, SkipNoSchema
 	}
-	for k, v := range src.data.dynamicBlocks {
-		if existing, exists := dest.data.dynamicBlocks[k]; exists {
-			mergeBlocks(existing, v)
-		} else {
-			dest.data.dynamicBlocks[k] = v
+
+	if bound {
+		for _, c := range candidates {
+			if strings.EqualFold(c.source, config.Source) {
+				if c.source != config.Source {
+					log.Logf("Matched provider schema %s case-insensitively for %s", c.source, config.Source)
+				}
+				return c.schema, ""
+			}
 		}
 	}
-	dest.data.ignoreChanges = append(dest.data.ignoreChanges, src.data.ignoreChanges...)
+	if len(candidates) > 1 {
+		log.Logf("Resource type %s is defined by %d providers, using %s", resType, len(candidates), candidates[0].source)
+	} else {
+		log.Logf("Resolved resource type %s to provider %s", resType, candidates[0].source)
+	}
+	return candidates[0].schema, ""
 }
 
-func logMissingAttribute(t *testing.T, resType, name, path string, required bool) {
-	status := "optional"
-	if required {
-		status = "required"
+// cloudSchema covers the settings a cloud block must declare. Optional
+// settings such as hostname and the workspaces selectors are left out so they
+// are not reported as missing.
+var cloudSchema = &SchemaBlock{
+	Attributes: map[string]*SchemaAttribute{
+		"organization": {Required: true},
+	},
+	BlockTypes: map[string]*SchemaBlockType{
+		"workspaces": {Nesting: "single", MinItems: 1, Block: &SchemaBlock{}},
+	},
+}
+
+// validateProviderBlocks checks provider configuration blocks against the
+// provider's own schema.
+func validateProviderBlocks(log Logger, mod *Module, tfSchema *TerraformSchema, opts *Options, findings *[]ValidationFinding) {
+	for _, p := range mod.ProviderBlocks {
+		config, ok := mod.Providers[p.Name]
+		if !ok {
+			log.Logf("No provider requirement for provider block %s", p.Address())
+			continue
+		}
+		providerSchema, key := lookupProviderSchema(tfSchema.ProviderSchemas, config.Source)
+		if providerSchema != nil && key != config.Source {
+			log.Logf("Matched provider schema %s case-insensitively for %s", key, config.Source)
+		}
+		if providerSchema == nil || providerSchema.Provider == nil {
+			continue
+		}
+		p.data.Validate(log, "provider."+p.Address(), "root", providerSchema.Provider.Block, nil, opts, findings)
+		if opts.requiredOnly() {
+			continue
+		}
+		// Provider settings usually come from variables or the environment;
+		// only literal values are checked, so no eval context is passed.
+		validateAllowedValues(log, ParsedResource{Type: "provider." + p.Name, data: p.data}, opts, nil, findings)
 	}
-	cleanPath := strings.ReplaceAll(path, "root.", "")
-	t.Logf("%s missing %s property %s in %s", resType, status, name, cleanPath)
 }
 
-func logMissingBlock(t *testing.T, resType, name, path string, required bool) {
-	status := "optional"
-	if required {
-		status = "required"
+// defaultCredentialAttributes lists well-known secret provider arguments.
+var defaultCredentialAttributes = map[string][]string{
+	"azurerm": {"client_secret", "client_certificate_password"},
+	"azuread": {"client_secret", "client_certificate_password"},
+	"aws":     {"access_key", "secret_key", "token"},
+	"google":  {"credentials", "access_token"},
+}
+
+func (o *Options) credentialAttributes(provider string) []string {
+	if o != nil {
+		if names, ok := o.CredentialAttributes[provider]; ok {
+			return names
+		}
 	}
-	cleanPath := strings.ReplaceAll(path, "root.", "")
-	t.Logf("%s missing %s block %s in %s", resType, status, name, cleanPath)
+	return defaultCredentialAttributes[provider]
 }
 
-func contains(list []string, s string) bool {
-	for _, v := range list {
-		if v == s {
-			return true
+// validateProviderCredentials flags credential arguments of provider blocks
+// that are set to literals instead of variables or the environment.
+func validateProviderCredentials(log Logger, providers []ParsedProvider, opts *Options, findings *[]ValidationFinding) {
+	for _, p := range providers {
+		for _, name := range opts.credentialAttributes(p.Name) {
+			attr := p.data.attributes[name]
+			if attr == nil || len(attr.Expr.Variables()) > 0 {
+				continue
+			}
+			if _, ok := literalValue(attr.Expr, nil); !ok {
+				continue
+			}
+			resType := "provider." + p.Address()
+			*findings = append(*findings, ValidationFinding{
+				ResourceType: resType,
+				Path:         "root",
+				Name:         name,
+				Kind:         FindingInvalid,
+				Message:      "credential is set to a literal value",
+			})
+			log.Logf("%s invalid property %s in root: credential is set to a literal value", resType, name)
 		}
 	}
-	return false
 }
 
-func parseGitRemote(remote string) (string, string) {
-	if strings.HasPrefix(remote, "https://") {
-		parts := strings.Split(remote, "/")
-		if len(parts) >= 4 {
-			return parts[3], strings.TrimSuffix(parts[4], ".git")
+// ValidateModule runs every check over the resources of mod.
+func ValidateModule(mod *Module, tfSchema *TerraformSchema, opts Options) Result {
+	log := opts.logger()
+	evalCtx := NewEvalContext(mod.Vars)
+
+	result := Result{Providers: len(mod.Providers), Resources: len(mod.Resources), Skipped: map[SkipReason]int{}}
+	var findings []ValidationFinding
+	validateProviderBlocks(log, mod, tfSchema, &opts, &findings)
+	schemas := newSchemaIndex(tfSchema)
+	// The module lints never produce required findings, so RequiredOnly
+	// skips them rather than logging findings that are dropped below.
+	if !opts.RequiredOnly {
+		validateProviderCredentials(log, mod.ProviderBlocks, &opts, &findings)
+		validateSensitiveOutputs(log, mod.Outputs, &opts, &findings)
+		validateSensitiveSchemaOutputs(log, mod, schemas, &findings)
+		validateOutputReferences(log, mod.Outputs, mod.Declared, &findings)
+		validateMovedBlocks(log, mod.Moved, mod.Declared, &findings)
+		validateProviderVersions(log, mod.Providers, &opts, &findings)
+		validateProviderPins(log, mod.Providers, &opts, &findings)
+		validateVersionFile(log, mod, &findings)
+		validateUnusedVariables(log, mod, &findings)
+	}
+	if mod.Cloud != nil {
+		mod.Cloud.data.Validate(log, "terraform.cloud", "root", cloudSchema, nil, &opts, &findings)
+	}
+
+	aliases := make(map[string]bool, len(mod.ProviderBlocks))
+	for _, p := range mod.ProviderBlocks {
+		aliases[p.Address()] = true
+	}
+
+	validateResources(log, mod, mod.Providers, schemas, aliases, evalCtx, &opts, &result, &findings)
+
+	if opts.RequiredOnly {
+		kept := findings[:0]
+		for _, f := range findings {
+			if f.Required {
+				kept = append(kept, f)
+			}
 		}
+		findings = kept
 	}
+	result.Findings = findings
+	return result
+}
 
-	if strings.HasPrefix(remote, "git@") {
-		parts := strings.Split(remote, ":")
-		if len(parts) == 2 {
-			repoParts := strings.Split(parts[1], "/")
-			if len(repoParts) >= 2 {
-				return repoParts[0], strings.TrimSuffix(repoParts[1], ".git")
+// validateResources runs the per-resource checks over mod.Resources, then
+// over the resources of each local child module with finding paths prefixed
+// by module.<name>. Children inherit the caller's providers.
+func validateResources(log Logger, mod *Module, providers map[string]ProviderConfig, schemas schemaIndex, aliases map[string]bool, evalCtx *hcl.EvalContext, opts *Options, result *Result, findings *[]ValidationFinding) {
+	tagCtx := withLocals(evalCtx, mod.Locals)
+	sources := SourceCache{}
+
+	for _, res := range mod.Resources {
+		if !opts.includesType(res.Type) {
+			log.Logf("Skipping resource type %s", res.Type)
+			result.Skipped[SkipExcluded]++
+			continue
+		}
+
+		if res.Kind == KindData {
+			// Data sources take lookup arguments, so the resource lints
+			// (tags, naming, lifecycle, hardcoded values) do not apply.
+			if !opts.requiredOnly() {
+				validateDependsOn(log, res, mod.Declared, findings)
+				validateDataReferences(log, res, mod.Declared, findings)
+			}
+			providerName, _ := resourceProvider(res)
+			dataSchema, reason := schemas.resolveResourceSchema(log, providers, providerName, res.Label())
+			if dataSchema == nil || dataSchema.Block == nil {
+				if reason == "" {
+					reason = SkipNoSchema
+				}
+				result.Skipped[reason]++
+				continue
+			}
+			if dataSchema.Block.computedOnly() {
+				log.Logf("Skipping %s, its schema is computed-only", res.Label())
+				result.Skipped[SkipComputedOnly]++
+				continue
+			}
+			res.data.Validate(log, res.Label(), "root", dataSchema.Block, nil, opts, findings)
+			res.data.validateUnknownAttributes(log, res.Label(), "root", dataSchema.Block, opts, findings)
+			result.Validated++
+			continue
+		}
+
+		if !opts.requiredOnly() {
+			validateDependsOn(log, res, mod.Declared, findings)
+			validateDependsOnCount(log, res, opts, findings)
+			validateRepetition(log, res, findings)
+			validateIndexedNames(log, res, opts, findings)
+			validateDataReferences(log, res, mod.Declared, findings)
+			validateResourceName(log, res, opts, findings)
+			validateTagKeys(log, res, opts, tagCtx, findings)
+			validateHardcodedValues(log, res, opts, findings)
+			validatePreventDestroy(log, res, opts, findings)
+			validateResourceComment(log, res, sources, opts, findings)
+		}
+
+		providerName, alias := resourceProvider(res)
+		if alias != "" && !aliases[providerName+"."+alias] && !opts.requiredOnly() {
+			msg := fmt.Sprintf("references undeclared provider %s.%s", providerName, alias)
+			*findings = append(*findings, ValidationFinding{
+				ResourceType: res.Type,
+				Path:         "root",
+				Name:         "provider",
+				Kind:         FindingInvalid,
+				Message:      msg,
+			})
+			log.Logf("%s invalid property provider in root: %s", res.Type, msg)
+		}
+
+		resourceSchema, reason := schemas.resolveResourceSchema(log, providers, providerName, res.Type)
+		if resourceSchema == nil || resourceSchema.Block == nil {
+			if reason == "" {
+				reason = SkipNoSchema
 			}
+			result.Skipped[reason]++
+			continue
+		}
+		if resourceSchema.Block.computedOnly() {
+			log.Logf("Skipping %s, its schema is computed-only", res.Type)
+			result.Skipped[SkipComputedOnly]++
+			continue
+		}
+
+		before := len(*findings)
+		res.data.Validate(log, res.Type, "root", resourceSchema.Block, nil, opts, findings)
+		annotateCommentedBlocks(log, res, sources, (*findings)[before:])
+		res.data.validateUnknownAttributes(log, res.Type, "root", resourceSchema.Block, opts, findings)
+		if !opts.requiredOnly() {
+			validateAllowedValues(log, res, opts, evalCtx, findings)
+			validateCIDRs(log, res, opts, evalCtx, findings)
+			validateNumericRanges(log, res, opts, findings)
 		}
+		runValidators(log, res, resourceSchema, opts, findings)
+		result.Validated++
 	}
 
-	return "", ""
+	for _, call := range mod.Calls {
+		if call.Module == nil {
+			if call.Local() {
+				log.Logf("Skipping module %s, no .tf files found at %s", call.Name, call.Source)
+			} else {
+				log.Logf("Skipping module %s with remote source %s", call.Name, call.Source)
+			}
+			continue
+		}
+		log.Logf("Validating module %s (%s)", call.Name, call.Source)
+		result.Resources += len(call.Module.Resources)
+		before := len(*findings)
+		validateResources(log, call.Module, providers, schemas, aliases, NewEvalContext(nil), opts, result, findings)
+		for i := range (*findings)[before:] {
+			f := &(*findings)[before+i]
+			f.Path = "module." + call.Name + "." + f.Path
+		}
+	}
 }
 
-func parseGitConfig(config string) (string, string) {
-	lines := strings.Split(config, "\n")
-	for i, line := range lines {
-		if strings.Contains(line, `[remote "origin"]`) {
-			for j := i + 1; j < len(lines); j++ {
-				if strings.HasPrefix(lines[j], "\turl = ") {
-					return parseGitRemote(strings.TrimPrefix(lines[j], "\turl = "))
-				}
+// Watch runs RunValidation once, then again whenever events delivers a
+// changed .tf or .tfvars path. Runs wait until no event arrived for debounce,
+// so a burst of saves triggers a single run. Watch requires opts.SchemaFile
+// to stay offline and returns when ctx is done or events is closed.
+func Watch(ctx context.Context, root string, events <-chan string, debounce time.Duration, opts Options, report func(Result, error)) error {
+	if opts.SchemaFile == "" {
+		return fmt.Errorf("watch mode requires a schema file")
+	}
+	report(RunValidation(root, opts))
+
+	var timer *time.Timer
+	var fire <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case path, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if ext := filepath.Ext(path); ext != ".tf" && ext != ".tfvars" {
+				continue
+			}
+			if timer == nil {
+				timer = time.NewTimer(debounce)
+			} else {
+				timer.Reset(debounce)
 			}
+			fire = timer.C
+		case <-fire:
+			fire = nil
+			report(RunValidation(root, opts))
 		}
 	}
-	return "", ""
 }
 
-func ParseSyntaxBody(body *hclsyntax.Body) *ParsedBlock {
-	bd := NewBlockData()
-	block := &ParsedBlock{data: bd}
-	block.data.ParseAttributes(body)
-	block.data.ParseBlocks(body)
-	return block
+// watchFiles forwards the paths of files changed in root until ctx is done.
+func watchFiles(ctx context.Context, root string) (<-chan string, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(root); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	events := make(chan string)
+	go func() {
+		defer close(events)
+		defer watcher.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if ev.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) == 0 {
+					continue
+				}
+				select {
+				case events <- ev.Name:
+				case <-ctx.Done():
+					return
+				}
+			case <-watcher.Errors:
+			}
+		}
+	}()
+	return events, nil
 }
 
 // Test function
@@ -583,81 +4521,145 @@ func TestValidateTerraformSchema(t *testing.T) {
 		terraformRoot = filepath.Join("..")
 	}
 
-	mainTfPath := filepath.Join(terraformRoot, "main.tf")
-	terraformTfPath := filepath.Join(terraformRoot, "terraform.tf")
-
-	if _, err := os.Stat(mainTfPath); err != nil {
-		t.Fatalf("No main.tf found at %s: %v", mainTfPath, err)
+	sortMode, err := ParseSortMode(os.Getenv("GOPHX_SORT"))
+	if err != nil {
+		t.Fatalf("Invalid GOPHX_SORT: %v", err)
 	}
 
-	var parser HCLParser = &DefaultHCLParser{}
-	providers, err := parser.ParseProviderRequirements(terraformTfPath)
+	opts, err := LoadOptions()
 	if err != nil {
-		t.Fatalf("Failed to parse provider config: %v", err)
+		t.Fatalf("Failed to load options: %v", err)
 	}
+	opts.Logger = t
 
-	// Cleanup previous Terraform files
-	t.Cleanup(func() {
-		os.RemoveAll(filepath.Join(terraformRoot, ".terraform"))
-		os.Remove(filepath.Join(terraformRoot, "terraform.tfstate"))
-		os.Remove(filepath.Join(terraformRoot, ".terraform.lock.hcl"))
-	})
+	run := NewRunContext(terraformRoot)
+	t.Logf("Run %s", run.ID)
 
-	initCmd := exec.CommandContext(context.Background(), "terraform", "init")
-	initCmd.Dir = terraformRoot
-	if out, err := initCmd.CombinedOutput(); err != nil {
-		t.Fatalf("terraform init failed: %v\nOutput: %s", err, string(out))
+	// GOPHX_WATCH=1 keeps re-validating on .tf changes until interrupted.
+	if envEnabled("GOPHX_WATCH") {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		events, err := watchFiles(ctx, terraformRoot)
+		if err != nil {
+			t.Fatalf("Failed to watch %s: %v", terraformRoot, err)
+		}
+		err = Watch(ctx, terraformRoot, events, 300*time.Millisecond, *opts, func(result Result, err error) {
+			if err != nil {
+				t.Logf("Validation failed: %v", err)
+				return
+			}
+			t.Logf("Validated %d of %d resources, %d findings", result.Validated, result.Resources, len(result.Findings))
+			for _, line := range strings.Split(strings.TrimSpace(FormatFindingsList(result.Findings)), "\n") {
+				if line != "" {
+					t.Log(line)
+				}
+			}
+		})
+		if err != nil && !errors.Is(err, context.Canceled) {
+			t.Fatalf("Watch failed: %v", err)
+		}
+		return
 	}
 
-	schemaCmd := exec.CommandContext(context.Background(), "terraform", "providers", "schema", "-json")
-	schemaCmd.Dir = terraformRoot
-	schemaBytes, err := schemaCmd.Output()
+	// GOPHX_INPUT=- reads a snippet from stdin; go test does not forward
+	// stdin, so pipe into the binary built by `go test -c` instead.
+	var result Result
+	if input := os.Getenv("GOPHX_INPUT"); input != "" {
+		result, err = ValidateInput(input, os.Stdin, *opts)
+	} else {
+		result, err = RunValidation(terraformRoot, *opts)
+	}
 	if err != nil {
-		t.Fatalf("Failed to get schema: %v", err)
+		t.Fatalf("Validation failed: %v", err)
+	}
+	findings := result.Findings
+	t.Logf("Validated %d of %d resources across %d providers, %d findings",
+		result.Validated, result.Resources, result.Providers, len(findings))
+	for _, reason := range []SkipReason{SkipExcluded, SkipNoProvider, SkipNoSchema, SkipComputedOnly} {
+		if n := result.Skipped[reason]; n > 0 {
+			t.Logf("Skipped %d resources: %s", n, reason)
+		}
 	}
 
-	var tfSchema TerraformSchema
-	if err := json.Unmarshal(schemaBytes, &tfSchema); err != nil {
-		t.Fatalf("Failed to decode schema: %v", err)
+	for _, nc := range TopMissing(findings, 5) {
+		t.Logf("Most commonly missing: %s (%d)", nc.Name, nc.Count)
 	}
 
-	resources, err := parser.ParseMainFile(mainTfPath)
-	if err != nil {
-		t.Fatalf("Failed to parse main.tf: %v", err)
+	if path := os.Getenv("GOPHX_FINDINGS_FILE"); path != "" {
+		if err := WriteFindingsFile(path, findings); err != nil {
+			t.Errorf("Failed to write findings file: %v", err)
+		}
 	}
 
-	var findings []ValidationFinding
-	for _, res := range resources {
-		providerName := strings.SplitN(res.Type, "_", 2)[0]
-		providerConfig, exists := providers[providerName]
-		if !exists {
-			t.Logf("No provider configured for resource type %s", res.Type)
-			continue
+	if path := os.Getenv("GOPHX_OUTPUT_JSON"); path != "" {
+		if err := WriteFindingsJSON(path, findings); err != nil {
+			t.Errorf("Failed to write findings JSON: %v", err)
 		}
+	}
 
-		providerSchema := tfSchema.ProviderSchemas[providerConfig.Source]
-		if providerSchema == nil {
-			t.Logf("No schema found for provider %s (%s)", providerName, providerConfig.Source)
-			continue
+	if path := os.Getenv("GOPHX_PROM_TEXTFILE"); path != "" {
+		if err := WritePromTextfile(path, findings); err != nil {
+			t.Errorf("Failed to write Prometheus textfile: %v", err)
 		}
+	}
 
-		resourceSchema := providerSchema.ResourceSchemas[res.Type]
-		if resourceSchema == nil {
-			continue
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" {
+		ctx := context.Background()
+		tp, err := newOTLPTracerProvider(ctx)
+		if err != nil {
+			t.Errorf("Failed to configure tracing: %v", err)
+		} else {
+			RecordFindings(ctx, tp.Tracer("gophx"), run, findings)
+			if err := tp.Shutdown(ctx); err != nil {
+				t.Errorf("Failed to export findings: %v", err)
+			}
 		}
+	}
 
-		res.data.Validate(t, res.Type, "root", resourceSchema.Block, nil, &findings)
+	if path := os.Getenv("GOPHX_BADGE_FILE"); path != "" {
+		if err := WriteBadgeFile(path, findings); err != nil {
+			t.Errorf("Failed to write badge file: %v", err)
+		}
 	}
 
-	if ghToken := os.Getenv("GITHUB_TOKEN"); ghToken != "" {
+	// One limiter bounds the API calls of every issue service in the run.
+	limiter := NewRequestLimiter(ghConcurrency())
+	if os.Getenv("GOPHX_ISSUE_PROVIDER") == "gitlab" {
+		repoInfo := &GitRepoInfo{terraformRoot: terraformRoot}
+		owner, name := repoInfo.GetRepoInfo()
+		if token := os.Getenv("GITLAB_TOKEN"); token == "" {
+			t.Log("GITLAB_TOKEN is required for GitLab issues")
+		} else if owner != "" && name != "" {
+			var issueManager IssueManager = &GitLabIssueService{
+				ProjectPath: owner + "/" + name,
+				BaseURL:     os.Getenv("CI_SERVER_URL"),
+				Module:      os.Getenv("GOPHX_MODULE"),
+				Sort:        sortMode,
+				Run:         run,
+				token:       token,
+				Client:      &http.Client{Timeout: 10 * time.Second},
+				Limiter:     limiter,
+			}
+			if err := issueManager.CreateOrUpdateIssue(findings); err != nil {
+				t.Errorf("Failed to manage GitLab issues: %v", err)
+			}
+		} else {
+			t.Log("Could not determine repository owner/name")
+		}
+	} else if ghToken := os.Getenv("GITHUB_TOKEN"); ghToken != "" {
 		repoInfo := &GitRepoInfo{terraformRoot: terraformRoot}
 		owner, name := repoInfo.GetRepoInfo()
 		if owner != "" && name != "" {
 			var issueManager IssueManager = &GitHubIssueService{
-				RepoOwner: owner,
-				RepoName:  name,
-				token:     ghToken,
-				Client:    &http.Client{Timeout: 10 * time.Second},
+				RepoOwner:  owner,
+				RepoName:   name,
+				token:      ghToken,
+				Client:     &http.Client{Timeout: 10 * time.Second},
+				Module:     os.Getenv("GOPHX_MODULE"),
+				PerFinding: envEnabled("GOPHX_ISSUE_PER_FINDING"),
+				Sort:       sortMode,
+				Run:        run,
+				Limiter:    limiter,
 			}
 			if err := issueManager.CreateOrUpdateIssue(findings); err != nil {
 				t.Errorf("Failed to manage GitHub issues: %v", err)
@@ -666,4 +4668,25 @@ func TestValidateTerraformSchema(t *testing.T) {
 			t.Log("Could not determine repository owner/name")
 		}
 	}
+
+	if adoToken := os.Getenv("AZDO_TOKEN"); adoToken != "" {
+		org, project := os.Getenv("AZDO_ORG"), os.Getenv("AZDO_PROJECT")
+		if org != "" && project != "" {
+			var issueManager IssueManager = &AzureDevOpsIssueService{
+				Organization: org,
+				Project:      project,
+				WorkItemType: os.Getenv("AZDO_WORK_ITEM_TYPE"),
+				Sort:         sortMode,
+				Run:          run,
+				token:        adoToken,
+				Client:       &http.Client{Timeout: 10 * time.Second},
+				Limiter:      limiter,
+			}
+			if err := issueManager.CreateOrUpdateIssue(findings); err != nil {
+				t.Errorf("Failed to manage Azure DevOps work items: %v", err)
+			}
+		} else {
+			t.Log("AZDO_ORG and AZDO_PROJECT are required for Azure DevOps work items")
+		}
+	}
 }
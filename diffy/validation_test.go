@@ -0,0 +1,2607 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zclconf/go-cty/cty"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// parseFixture writes src as main.tf in a temp dir and parses its resources.
+func parseFixture(t *testing.T, src string) []ParsedResource {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "main.tf")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	parser := &DefaultHCLParser{}
+	resources, err := parser.ParseMainFile(path)
+	if err != nil {
+		t.Fatalf("parse fixture: %v", err)
+	}
+	return resources
+}
+
+// writeModule writes files into a temp dir and returns its path.
+func writeModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+const azurermTerraformTf = `
+terraform {
+  required_providers {
+    azurerm = {
+      source  = "hashicorp/azurerm"
+      version = "~> 4.0"
+    }
+  }
+}
+`
+
+// moduleFixture parses main.tf src alongside an azurerm terraform.tf.
+func moduleFixture(t *testing.T, src string) *Module {
+	t.Helper()
+	dir := writeModule(t, map[string]string{"main.tf": src, "terraform.tf": azurermTerraformTf})
+	mod, err := ParseModule(&DefaultHCLParser{}, dir)
+	if err != nil {
+		t.Fatalf("parse module: %v", err)
+	}
+	return mod
+}
+
+// providerSchemaFixture wraps resource schemas (JSON) as the azurerm provider.
+func providerSchemaFixture(t *testing.T, resourceSchemas string) *TerraformSchema {
+	t.Helper()
+	schema, err := DecodeSchema([]byte(`{"provider_schemas": {"registry.terraform.io/hashicorp/azurerm": {"resource_schemas": ` + resourceSchemas + `}}}`))
+	if err != nil {
+		t.Fatalf("decode schema fixture: %v", err)
+	}
+	return schema
+}
+
+// schemaFixture decodes a resource schema block from JSON.
+func schemaFixture(t *testing.T, src string) *SchemaBlock {
+	t.Helper()
+	var block SchemaBlock
+	if err := json.Unmarshal([]byte(src), &block); err != nil {
+		t.Fatalf("decode schema fixture: %v", err)
+	}
+	return &block
+}
+
+func findFinding(findings []ValidationFinding, path, name string) (ValidationFinding, bool) {
+	for _, f := range findings {
+		if f.Path == path && f.Name == name {
+			return f, true
+		}
+	}
+	return ValidationFinding{}, false
+}
+
+func TestEmptyBlockReportsRequiredAttribute(t *testing.T) {
+	resources := parseFixture(t, `
+resource "azurerm_user_assigned_identity" "example" {
+  name = "example"
+
+  identity {}
+}
+`)
+	schema := schemaFixture(t, `{
+  "attributes": {
+    "name": {"required": true}
+  },
+  "block_types": {
+    "identity": {
+      "nesting": "list",
+      "max_items": 1,
+      "block": {
+        "attributes": {
+          "type": {"required": true},
+          "identity_ids": {"optional": true}
+        }
+      }
+    }
+  }
+}`)
+
+	var findings []ValidationFinding
+	res := resources[0]
+	res.data.Validate(t, res.Type, "root", schema, nil, nil, &findings)
+
+	f, ok := findFinding(findings, "root.identity", "type")
+	if !ok {
+		t.Fatalf("expected finding for root.identity.type, got %+v", findings)
+	}
+	if !f.Required || f.IsBlock {
+		t.Errorf("expected required property finding, got %+v", f)
+	}
+	if _, ok := findFinding(findings, "root", "identity"); ok {
+		t.Errorf("declared empty block must not be reported as missing")
+	}
+}
+
+func TestTfvarsDrivenAllowedValues(t *testing.T) {
+	dir := t.TempDir()
+	tfvarsPath := filepath.Join(dir, "terraform.tfvars")
+	if err := os.WriteFile(tfvarsPath, []byte(`account_tier = "Premium2"`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	parser := &DefaultHCLParser{}
+	vars, err := parser.ParseTfvars(tfvarsPath)
+	if err != nil {
+		t.Fatalf("ParseTfvars: %v", err)
+	}
+
+	resources := parseFixture(t, `
+resource "azurerm_storage_account" "example" {
+  account_tier             = var.account_tier
+  account_replication_type = "LRS"
+  access_tier              = var.undeclared
+}
+`)
+	opts := &Options{AllowedValues: map[string]map[string][]string{
+		"azurerm_storage_account": {
+			"account_tier":             {"Standard", "Premium"},
+			"account_replication_type": {"LRS", "GRS"},
+			"access_tier":              {"Hot", "Cool"},
+		},
+	}}
+
+	var findings []ValidationFinding
+	validateAllowedValues(t, resources[0], opts, NewEvalContext(vars), &findings)
+
+	if len(findings) != 1 {
+		t.Fatalf("expected exactly one finding, got %+v", findings)
+	}
+	if f := findings[0]; f.Name != "account_tier" || f.Kind != FindingInvalid {
+		t.Errorf("unexpected finding %+v", f)
+	}
+}
+
+func TestRequireTagsUpgradesMissingTags(t *testing.T) {
+	resources := parseFixture(t, `
+resource "azurerm_resource_group" "example" {
+  name     = "rg-example"
+  location = "westeurope"
+}
+
+resource "azurerm_role_assignment" "example" {
+  scope = "/subscriptions/0000"
+}
+`)
+	taggable := schemaFixture(t, `{"attributes": {
+  "name": {"required": true},
+  "location": {"required": true},
+  "tags": {"optional": true}
+}}`)
+	untaggable := schemaFixture(t, `{"attributes": {
+  "scope": {"required": true}
+}}`)
+
+	for _, requireTags := range []bool{false, true} {
+		opts := &Options{RequireTags: requireTags}
+		var findings []ValidationFinding
+		resources[0].data.Validate(t, resources[0].Type, "root", taggable, nil, opts, &findings)
+		resources[1].data.Validate(t, resources[1].Type, "root", untaggable, nil, opts, &findings)
+
+		if len(findings) != 1 {
+			t.Fatalf("require_tags=%v: expected one finding, got %+v", requireTags, findings)
+		}
+		f := findings[0]
+		if f.ResourceType != "azurerm_resource_group" || f.Name != "tags" || f.Required != requireTags {
+			t.Errorf("require_tags=%v: unexpected finding %+v", requireTags, f)
+		}
+	}
+}
+
+func TestDecodeSchemaRejectsEmptyOutput(t *testing.T) {
+	for name, out := range map[string]string{
+		"empty":        "",
+		"whitespace":   "\n  \n",
+		"no providers": `{"format_version": "1.0"}`,
+		"empty map":    `{"format_version": "1.0", "provider_schemas": {}}`,
+	} {
+		if _, err := DecodeSchema([]byte(out)); !errors.Is(err, ErrEmptySchema) {
+			t.Errorf("%s: expected ErrEmptySchema, got %v", name, err)
+		}
+	}
+
+	schema, err := DecodeSchema([]byte(`{"provider_schemas": {"registry.terraform.io/hashicorp/azurerm": {}}}`))
+	if err != nil || len(schema.ProviderSchemas) != 1 {
+		t.Errorf("expected one provider schema, got %v, %v", schema, err)
+	}
+}
+
+func TestDependsOnReferencesDeclaredAddresses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.tf")
+	src := `
+data "azurerm_client_config" "current" {}
+
+module "network" {
+  source = "./modules/network"
+}
+
+resource "azurerm_resource_group" "example" {
+  name     = "rg-example"
+  location = "westeurope"
+}
+
+resource "azurerm_key_vault" "valid" {
+  depends_on = [
+    azurerm_resource_group.example,
+    data.azurerm_client_config.current,
+    module.network,
+  ]
+}
+
+resource "azurerm_key_vault" "dangling" {
+  depends_on = [azurerm_resource_group.missing, module.storage]
+}
+`
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	parser := &DefaultHCLParser{}
+	resources, err := parser.ParseMainFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	declared, err := parser.ParseDeclaredAddresses(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var findings []ValidationFinding
+	for _, res := range resources {
+		before := len(findings)
+		validateDependsOn(t, res, declared, &findings)
+		if res.Name == "valid" && len(findings) != before {
+			t.Errorf("valid depends_on produced findings: %+v", findings[before:])
+		}
+	}
+
+	if len(findings) != 2 {
+		t.Fatalf("expected two dangling references, got %+v", findings)
+	}
+	for i, addr := range []string{"azurerm_resource_group.missing", "module.storage"} {
+		if want := "references undeclared " + addr; findings[i].Message != want {
+			t.Errorf("finding %d: got %q, want %q", i, findings[i].Message, want)
+		}
+	}
+}
+
+func TestDependsOnOveruse(t *testing.T) {
+	resources := parseFixture(t, `
+resource "azurerm_linux_web_app" "heavy" {
+  depends_on = [
+    azurerm_resource_group.example,
+    azurerm_service_plan.example,
+    azurerm_key_vault.example,
+    azurerm_storage_account.example,
+  ]
+}
+
+resource "azurerm_linux_web_app" "light" {
+  depends_on = [azurerm_resource_group.example, azurerm_service_plan.example]
+}
+`)
+	opts := &Options{MaxDependsOn: 3}
+
+	var findings []ValidationFinding
+	for _, res := range resources {
+		validateDependsOnCount(t, res, opts, &findings)
+	}
+
+	if len(findings) != 1 {
+		t.Fatalf("expected one finding, got %+v", findings)
+	}
+	want := ValidationFinding{
+		ResourceType: "azurerm_linux_web_app",
+		Path:         "root",
+		Name:         "depends_on",
+		Kind:         FindingInfo,
+		Message:      "4 explicit dependencies, more than 3; prefer implicit references",
+	}
+	if findings[0] != want {
+		t.Errorf("got %+v, want %+v", findings[0], want)
+	}
+}
+
+func TestTopMissingRanksByCount(t *testing.T) {
+	var findings []ValidationFinding
+	add := func(name string, n int) {
+		for i := 0; i < n; i++ {
+			findings = append(findings, ValidationFinding{ResourceType: "azurerm_x", Path: "root", Name: name, Kind: FindingMissing})
+		}
+	}
+	add("tags", 4)
+	add("identity", 2)
+	add("timeouts", 2)
+	add("zones", 1)
+	findings = append(findings, ValidationFinding{Name: "sku", Kind: FindingInvalid})
+
+	got := TopMissing(findings, 3)
+	want := []NameCount{{"tags", 4}, {"identity", 2}, {"timeouts", 2}}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("rank %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestResourceNamePattern(t *testing.T) {
+	resources := parseFixture(t, `
+resource "azurerm_resource_group" "this" {}
+resource "azurerm_resource_group" "MyGroup" {}
+`)
+	opts := &Options{NamePattern: regexp.MustCompile(`^[a-z][a-z0-9_]*$`)}
+
+	var findings []ValidationFinding
+	for _, res := range resources {
+		validateResourceName(t, res, opts, &findings)
+	}
+
+	if len(findings) != 1 || findings[0].Name != "MyGroup" {
+		t.Fatalf("expected only MyGroup to be flagged, got %+v", findings)
+	}
+}
+
+func TestParseGitConfigIndentation(t *testing.T) {
+	cases := map[string]string{
+		"tab indented":   "[core]\n\tbare = false\n[remote \"origin\"]\n\turl = https://github.com/dkooll/gophx.git\n",
+		"space indented": "[remote \"origin\"]\n    url=git@github.com:dkooll/gophx.git\n    fetch = +refs/heads/*:refs/remotes/origin/*\n",
+		"origin not first": "[remote \"upstream\"]\n\turl = https://github.com/other/fork.git\n" +
+			"[branch \"main\"]\n\tremote = upstream\n" +
+			"[remote  \"origin\"]\n  url   =   https://github.com/dkooll/gophx\n",
+	}
+	for name, config := range cases {
+		owner, repo := parseGitConfig(config)
+		if owner != "dkooll" || repo != "gophx" {
+			t.Errorf("%s: got %q/%q", name, owner, repo)
+		}
+	}
+}
+
+func TestReadFileLimited(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, make([]byte, 64), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readFileLimited(path, 64); err != nil {
+		t.Errorf("file at the limit should be read: %v", err)
+	}
+	if _, err := readFileLimited(path, 63); err == nil {
+		t.Error("expected error for file over the limit")
+	}
+}
+
+func TestComputedOnlyAttributeSet(t *testing.T) {
+	resources := parseFixture(t, `
+resource "azurerm_storage_account" "example" {
+  name               = "stexample"
+  primary_access_key = "not-allowed"
+  min_tls_version    = "TLS1_2"
+}
+`)
+	schema := schemaFixture(t, `{"attributes": {
+  "name": {"required": true},
+  "primary_access_key": {"computed": true},
+  "min_tls_version": {"optional": true, "computed": true}
+}}`)
+
+	var findings []ValidationFinding
+	resources[0].data.Validate(t, resources[0].Type, "root", schema, nil, nil, &findings)
+
+	if len(findings) != 1 {
+		t.Fatalf("expected one finding, got %+v", findings)
+	}
+	if f := findings[0]; f.Name != "primary_access_key" || f.Kind != FindingInvalid || f.Message != "cannot set computed attribute" {
+		t.Errorf("unexpected finding %+v", f)
+	}
+}
+
+func TestProviderSchemaLookupIgnoresCase(t *testing.T) {
+	tfSchema, err := DecodeSchema([]byte(`{"provider_schemas": {
+  "registry.terraform.io/Hashicorp/AzureRM": {
+    "resource_schemas": {
+      "azurerm_resource_group": {"block": {"attributes": {"location": {"required": true}}}}
+    }
+  }
+}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source := normalizeSource("hashicorp/azurerm")
+	providerSchema, key := lookupProviderSchema(tfSchema.ProviderSchemas, source)
+	if providerSchema == nil {
+		t.Fatalf("no schema found for %s", source)
+	}
+	if key != "registry.terraform.io/Hashicorp/AzureRM" {
+		t.Errorf("unexpected matched key %q", key)
+	}
+
+	resources := parseFixture(t, `resource "azurerm_resource_group" "example" {}`)
+	var findings []ValidationFinding
+	resSchema := providerSchema.ResourceSchemas["azurerm_resource_group"]
+	resources[0].data.Validate(t, resources[0].Type, "root", resSchema.Block, nil, nil, &findings)
+	if _, ok := findFinding(findings, "root", "location"); !ok {
+		t.Errorf("resource was not validated, findings: %+v", findings)
+	}
+}
+
+func TestResourceSchemaCaseInsensitiveMatchIsLogged(t *testing.T) {
+	mod := moduleFixture(t, `resource "azurerm_resource_group" "example" {}`)
+	tfSchema, err := DecodeSchema([]byte(`{"provider_schemas": {
+  "registry.terraform.io/Hashicorp/AzureRM": {
+    "resource_schemas": {
+      "azurerm_resource_group": {"block": {"attributes": {"location": {"required": true}}}}
+    }
+  }
+}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log := &recordLogger{}
+	result := ValidateModule(mod, tfSchema, Options{Logger: log})
+
+	if _, ok := findFinding(result.Findings, "root", "location"); !ok {
+		t.Errorf("resource was not validated, findings: %+v", result.Findings)
+	}
+	want := "Matched provider schema registry.terraform.io/Hashicorp/AzureRM case-insensitively for registry.terraform.io/hashicorp/azurerm"
+	if !slices.Contains(log.lines, want) {
+		t.Errorf("case-insensitive match was not logged, got %q", log.lines)
+	}
+}
+
+func TestDynamicBlockWithoutContent(t *testing.T) {
+	resources := parseFixture(t, `
+resource "azurerm_network_security_group" "example" {
+  name = "nsg-example"
+
+  dynamic "security_rule" {
+    for_each = var.rules
+    name     = security_rule.value.name
+  }
+}
+`)
+	schema := schemaFixture(t, `{
+  "attributes": {"name": {"required": true}},
+  "block_types": {
+    "security_rule": {
+      "nesting": "set",
+      "min_items": 1,
+      "block": {"attributes": {"name": {"required": true}}}
+    }
+  }
+}`)
+
+	var findings []ValidationFinding
+	resources[0].data.Validate(t, resources[0].Type, "root", schema, nil, nil, &findings)
+
+	if len(findings) != 1 {
+		t.Fatalf("expected a single finding, got %+v", findings)
+	}
+	if f := findings[0]; f.Name != "security_rule" || f.Message != "dynamic block missing content" {
+		t.Errorf("unexpected finding %+v", f)
+	}
+}
+
+func TestUnknownDynamicBlockLabel(t *testing.T) {
+	resources := parseFixture(t, `
+resource "azurerm_lb" "example" {
+  name = "lb-example"
+
+  dynamic "frontend_ip_configuraton" {
+    for_each = var.frontends
+    content {
+      name = frontend_ip_configuraton.value.name
+    }
+  }
+}
+`)
+	schema := schemaFixture(t, `{
+  "attributes": {"name": {"required": true}},
+  "block_types": {
+    "frontend_ip_configuration": {"nesting": "list", "min_items": 1, "block": {"attributes": {"name": {"required": true}}}}
+  }
+}`)
+
+	var findings []ValidationFinding
+	resources[0].data.Validate(t, resources[0].Type, "root", schema, nil, nil, &findings)
+
+	f, ok := findFinding(findings, "root", "frontend_ip_configuraton")
+	if !ok || f.Kind != FindingInvalid || f.Message != "unknown dynamic block" {
+		t.Errorf("expected unknown dynamic block finding, got %+v", findings)
+	}
+	if f, ok := findFinding(findings, "root", "frontend_ip_configuration"); !ok || f.Kind != FindingMissing {
+		t.Errorf("expected the required block to still be missing, got %+v", findings)
+	}
+}
+
+func TestDynamicBlockStaleIteratorReference(t *testing.T) {
+	resources := parseFixture(t, `
+resource "azurerm_network_security_group" "example" {
+  name = "nsg-example"
+
+  dynamic "security_rule" {
+    for_each = var.rules
+    iterator = rule
+    content {
+      name = security_rule.value.name
+    }
+  }
+
+  dynamic "tag" {
+    for_each = var.tags
+    iterator = t
+    content {
+      key = t.key
+    }
+  }
+}
+`)
+	schema := schemaFixture(t, `{
+  "attributes": {"name": {"required": true}},
+  "block_types": {
+    "security_rule": {"nesting": "set", "block": {"attributes": {"name": {"required": true}}}},
+    "tag": {"nesting": "set", "block": {"attributes": {"key": {"required": true}}}}
+  }
+}`)
+
+	var findings []ValidationFinding
+	resources[0].data.Validate(t, resources[0].Type, "root", schema, nil, nil, &findings)
+
+	if len(findings) != 1 {
+		t.Fatalf("expected a single finding, got %+v", findings)
+	}
+	if f := findings[0]; f.Name != "security_rule" || f.Message != "content references security_rule but the iterator is rule" {
+		t.Errorf("unexpected finding %+v", f)
+	}
+}
+
+func TestRequiredAttributeOverlay(t *testing.T) {
+	resources := parseFixture(t, `
+resource "azurerm_storage_account" "example" {
+  name = "stexample"
+}
+`)
+	schema := schemaFixture(t, `{"attributes": {
+  "name": {"required": true},
+  "min_tls_version": {"optional": true},
+  "access_tier": {"optional": true}
+}}`)
+	opts := &Options{RequiredAttributes: map[string][]string{
+		"azurerm_storage_account": {"min_tls_version"},
+	}}
+
+	var findings []ValidationFinding
+	resources[0].data.Validate(t, resources[0].Type, "root", schema, nil, opts, &findings)
+
+	if f, ok := findFinding(findings, "root", "min_tls_version"); !ok || !f.Required {
+		t.Errorf("expected min_tls_version to be required, got %+v", findings)
+	}
+	if f, ok := findFinding(findings, "root", "access_tier"); !ok || f.Required {
+		t.Errorf("expected access_tier to stay optional, got %+v", findings)
+	}
+}
+
+func TestCIDRAttributes(t *testing.T) {
+	resources := parseFixture(t, `
+resource "azurerm_virtual_network" "example" {
+  address_space = ["10.0.0.0/16", "10.1.0.0/33"]
+
+  subnet {
+    name           = "valid"
+    address_prefix = "10.0.1.0/24"
+  }
+}
+
+resource "azurerm_subnet" "example" {
+  address_prefixes = ["10.0.2.0/24"]
+  address_prefix   = "10.0.2.0"
+  other_prefix     = var.prefix
+}
+`)
+	opts := &Options{CIDRAttributes: []string{"address_space", "address_prefixes", "address_prefix", "other_prefix"}}
+	ctx := NewEvalContext(nil)
+
+	var findings []ValidationFinding
+	for _, res := range resources {
+		validateCIDRs(t, res, opts, ctx, &findings)
+	}
+
+	if len(findings) != 2 {
+		t.Fatalf("expected two malformed CIDRs, got %+v", findings)
+	}
+	if f := findings[0]; f.ResourceType != "azurerm_virtual_network" || f.Message != `"10.1.0.0/33" is not a valid CIDR` {
+		t.Errorf("unexpected finding %+v", f)
+	}
+	if f := findings[1]; f.ResourceType != "azurerm_subnet" || f.Name != "address_prefix" {
+		t.Errorf("unexpected finding %+v", f)
+	}
+}
+
+func TestRequiredTagKeysThroughMerge(t *testing.T) {
+	mod := moduleFixture(t, `
+locals {
+  common_tags = {
+    environment = "prod"
+  }
+  base_tags = merge(local.common_tags, {
+    owner = "platform"
+  })
+}
+
+resource "azurerm_resource_group" "merged" {
+  tags = merge(local.common_tags, {
+    workload = "api"
+  })
+}
+
+resource "azurerm_resource_group" "nested" {
+  tags = merge(local.base_tags, {
+    cost_center = "1234"
+  })
+}
+
+resource "azurerm_resource_group" "unresolved" {
+  tags = merge(local.common_tags, var.extra_tags)
+}
+`)
+
+	var findings []ValidationFinding
+	ctx := withLocals(NewEvalContext(mod.Vars), mod.Locals)
+	for _, res := range mod.Resources {
+		validateTagKeys(t, res, &Options{RequiredTagKeys: []string{"environment", "owner", "cost_center"}}, ctx, &findings)
+	}
+
+	if len(findings) != 1 {
+		t.Fatalf("expected one finding, got %+v", findings)
+	}
+	if f := findings[0]; f.Name != "tags" || f.Message != "tags missing required keys owner, cost_center" {
+		t.Errorf("unexpected finding %+v", f)
+	}
+}
+
+func TestHardcodedLocation(t *testing.T) {
+	resources := parseFixture(t, `
+resource "azurerm_resource_group" "hardcoded" {
+  location = "eastus"
+}
+
+resource "azurerm_resource_group" "variable" {
+  location = var.location
+}
+`)
+	opts := &Options{VariableAttributes: []string{"location", "region"}}
+
+	var findings []ValidationFinding
+	for _, res := range resources {
+		validateHardcodedValues(t, res, opts, &findings)
+	}
+
+	if len(findings) != 1 {
+		t.Fatalf("expected one finding, got %+v", findings)
+	}
+	if f := findings[0]; f.Name != "location" || f.Message != `hardcoded value "eastus" should reference a variable` {
+		t.Errorf("unexpected finding %+v", f)
+	}
+}
+
+func TestNumericRanges(t *testing.T) {
+	resources := parseFixture(t, `
+resource "azurerm_search_service" "too_many" {
+  replica_count   = 150
+  partition_count = 0
+}
+
+resource "azurerm_search_service" "in_range" {
+  replica_count   = 3
+  partition_count = var.partitions
+}
+`)
+	var opts Options
+	err := json.Unmarshal([]byte(`{"numeric_ranges": {"azurerm_search_service": {
+  "replica_count": {"min": 1, "max": 100},
+  "partition_count": {"min": 1}
+}}}`), &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var findings []ValidationFinding
+	for _, res := range resources {
+		validateNumericRanges(t, res, &opts, &findings)
+	}
+
+	if len(findings) != 2 {
+		t.Fatalf("expected two out-of-range values, got %+v", findings)
+	}
+	if f := findings[0]; f.Name != "partition_count" || f.Message != "value 0 is below minimum 1" {
+		t.Errorf("unexpected finding %+v", f)
+	}
+	if f := findings[1]; f.Name != "replica_count" || f.Message != "value 150 exceeds maximum 100" {
+		t.Errorf("unexpected finding %+v", f)
+	}
+}
+
+func TestCommentedOutRequiredBlock(t *testing.T) {
+	resources := parseFixture(t, `
+resource "azurerm_kubernetes_cluster" "example" {
+  name = "aks-example"
+
+  # default_node_pool {
+  #   name    = "default"
+  #   vm_size = "Standard_D2_v2"
+  # }
+
+  /*
+  identity {
+    type = "SystemAssigned"
+  }
+  */
+}
+`)
+	schema := schemaFixture(t, `{
+  "attributes": {"name": {"required": true}},
+  "block_types": {
+    "default_node_pool": {"nesting": "list", "min_items": 1, "max_items": 1, "block": {}},
+    "identity": {"nesting": "list", "min_items": 1, "max_items": 1, "block": {}},
+    "network_profile": {"nesting": "list", "min_items": 1, "max_items": 1, "block": {}}
+  }
+}`)
+
+	var findings []ValidationFinding
+	resources[0].data.Validate(t, resources[0].Type, "root", schema, nil, nil, &findings)
+	annotateCommentedBlocks(t, resources[0], SourceCache{}, findings)
+
+	for name, want := range map[string]string{
+		"default_node_pool": "block is present but commented out",
+		"identity":          "block is present but commented out",
+		"network_profile":   "",
+	} {
+		f, ok := findFinding(findings, "root", name)
+		if !ok {
+			t.Errorf("expected missing block %s", name)
+			continue
+		}
+		if f.Message != want {
+			t.Errorf("%s: got message %q, want %q", name, f.Message, want)
+		}
+	}
+}
+
+func TestRunValidationFixture(t *testing.T) {
+	if _, err := exec.LookPath("terraform"); err != nil {
+		t.Skip("terraform not available")
+	}
+
+	dir := writeModule(t, map[string]string{
+		"terraform.tf": `
+terraform {
+  required_providers {
+    random = {
+      source  = "hashicorp/random"
+      version = "~> 3.0"
+    }
+  }
+}
+`,
+		"main.tf": `
+resource "random_password" "example" {
+  special = false
+}
+`,
+	})
+
+	result, err := RunValidation(dir, Options{Logger: t})
+	if err != nil {
+		t.Fatalf("RunValidation: %v", err)
+	}
+	if result.Providers != 1 || result.Resources != 1 || result.Validated != 1 {
+		t.Errorf("unexpected counts %+v", result)
+	}
+	if f, ok := findFinding(result.Findings, "root", "length"); !ok || !f.Required {
+		t.Errorf("expected required length finding, got %+v", result.Findings)
+	}
+}
+
+func TestCustomValidator(t *testing.T) {
+	mod := moduleFixture(t, `
+resource "azurerm_resource_group" "legacy" {
+  location = "westeurope"
+}
+
+resource "azurerm_resource_group" "example" {
+  location = "westeurope"
+}
+`)
+	schema := providerSchemaFixture(t, `{
+  "azurerm_resource_group": {"block": {"attributes": {"location": {"required": true}}}}
+}`)
+
+	opts := Options{Logger: t}
+	opts.RegisterValidator(func(res ParsedResource, schema *ResourceSchema) []ValidationFinding {
+		if res.Name != "legacy" || schema == nil {
+			return nil
+		}
+		return []ValidationFinding{{Name: "name", Message: "legacy resources must be renamed"}}
+	})
+
+	result := ValidateModule(mod, schema, opts)
+
+	if len(result.Findings) != 1 {
+		t.Fatalf("expected one finding, got %+v", result.Findings)
+	}
+	want := ValidationFinding{ResourceType: "azurerm_resource_group", Path: "root", Name: "name", Kind: FindingInvalid, Message: "legacy resources must be renamed"}
+	if result.Findings[0] != want {
+		t.Errorf("got %+v, want %+v", result.Findings[0], want)
+	}
+}
+
+func TestSkipTypesTakesPrecedence(t *testing.T) {
+	mod := moduleFixture(t, `
+resource "azurerm_resource_group" "example" {}
+resource "azurerm_monitor_diagnostic_setting" "example" {}
+`)
+	schema := providerSchemaFixture(t, `{
+  "azurerm_resource_group": {"block": {"attributes": {"location": {"required": true}}}},
+  "azurerm_monitor_diagnostic_setting": {"block": {"attributes": {"target_resource_id": {"required": true}}}}
+}`)
+
+	result := ValidateModule(mod, schema, Options{
+		Logger:    t,
+		OnlyTypes: []string{"azurerm_resource_group", "azurerm_monitor_diagnostic_setting"},
+		SkipTypes: []string{"azurerm_monitor_diagnostic_setting"},
+	})
+
+	if result.Validated != 1 {
+		t.Errorf("expected one validated resource, got %d", result.Validated)
+	}
+	for _, f := range result.Findings {
+		if f.ResourceType == "azurerm_monitor_diagnostic_setting" {
+			t.Errorf("skipped type produced finding %+v", f)
+		}
+	}
+	if _, ok := findFinding(result.Findings, "root", "location"); !ok {
+		t.Errorf("expected finding for azurerm_resource_group, got %+v", result.Findings)
+	}
+}
+
+func TestDynamicBlockEmptyForEach(t *testing.T) {
+	resources := parseFixture(t, `
+resource "azurerm_application_gateway" "empty_list" {
+  dynamic "frontend_port" {
+    for_each = []
+    content {
+      port = frontend_port.value
+    }
+  }
+}
+
+resource "azurerm_application_gateway" "empty_map" {
+  dynamic "frontend_port" {
+    for_each = {}
+    content {
+      port = frontend_port.value
+    }
+  }
+}
+
+resource "azurerm_application_gateway" "variable" {
+  dynamic "frontend_port" {
+    for_each = var.ports
+    content {
+      port = frontend_port.value
+    }
+  }
+}
+`)
+	schema := schemaFixture(t, `{"block_types": {
+  "frontend_port": {"nesting": "set", "min_items": 1, "block": {"attributes": {"port": {"required": true}}}}
+}}`)
+
+	for _, res := range resources {
+		var findings []ValidationFinding
+		res.data.Validate(t, res.Type, "root", schema, nil, nil, &findings)
+
+		_, flagged := findFinding(findings, "root", "frontend_port")
+		if want := res.Name != "variable"; flagged != want {
+			t.Errorf("%s: flagged=%v, want %v (%+v)", res.Name, flagged, want, findings)
+		}
+	}
+}
+
+func TestAliasedProviderBlocks(t *testing.T) {
+	mod := moduleFixture(t, `
+provider "azurerm" {
+  alias           = "primary"
+  subscription_id = var.primary_subscription_id
+  features {}
+}
+
+provider "azurerm" {
+  alias = "secondary"
+  features {}
+}
+
+resource "azurerm_resource_group" "primary" {
+  provider = azurerm.primary
+  location = "westeurope"
+}
+
+resource "azurerm_resource_group" "secondary" {
+  provider = azurerm.secondary
+  location = "northeurope"
+}
+`)
+	schema, err := DecodeSchema([]byte(`{"provider_schemas": {"registry.terraform.io/hashicorp/azurerm": {
+  "provider": {"block": {
+    "attributes": {"subscription_id": {"required": true}},
+    "block_types": {"features": {"nesting": "list", "min_items": 1, "max_items": 1, "block": {}}}
+  }},
+  "resource_schemas": {
+    "azurerm_resource_group": {"block": {"attributes": {"location": {"required": true}, "name": {"required": true}}}}
+  }
+}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := ValidateModule(mod, schema, Options{Logger: t})
+
+	if result.Validated != 2 {
+		t.Errorf("expected both resources validated, got %d", result.Validated)
+	}
+	var providerFindings []ValidationFinding
+	for _, f := range result.Findings {
+		if f.Name == "alias" {
+			t.Errorf("alias reported as finding: %+v", f)
+		}
+		if strings.HasPrefix(f.ResourceType, "provider.") {
+			providerFindings = append(providerFindings, f)
+		}
+	}
+	if len(providerFindings) != 1 || providerFindings[0].ResourceType != "provider.azurerm.secondary" || providerFindings[0].Name != "subscription_id" {
+		t.Errorf("expected only secondary provider missing subscription_id, got %+v", providerFindings)
+	}
+	names := 0
+	for _, f := range result.Findings {
+		if f.ResourceType == "azurerm_resource_group" && f.Name == "name" {
+			names++
+		}
+	}
+	if names != 2 {
+		t.Errorf("expected both resource groups to report missing name, got %d", names)
+	}
+}
+
+func TestSensitiveOutputs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outputs.tf")
+	src := `
+output "admin_password" {
+  value = random_password.admin.result
+}
+
+output "admin_password_flagged" {
+  value     = random_password.admin.result
+  sensitive = true
+}
+
+output "storage" {
+  value = azurerm_storage_account.example.primary_access_key
+}
+
+output "resource_group_name" {
+  value = azurerm_resource_group.example.name
+}
+
+output "key_vault_id" {
+  value = azurerm_key_vault.example.id
+}
+
+output "monkey" {
+  value = azurerm_storage_account.example.primary_blob_endpoint
+}
+
+output "secret_reference" {
+  value = azurerm_key_vault.example.key_vault_id
+}
+`
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	outputs, err := (&DefaultHCLParser{}).ParseOutputs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var findings []ValidationFinding
+	validateSensitiveOutputs(t, outputs, nil, &findings)
+
+	var flagged []string
+	for _, f := range findings {
+		flagged = append(flagged, f.Name)
+	}
+	if strings.Join(flagged, ",") != "admin_password,storage" {
+		t.Errorf("unexpected flagged outputs %v", flagged)
+	}
+}
+
+func TestValidateInputFromStdin(t *testing.T) {
+	schemaPath := filepath.Join(t.TempDir(), "schema.json")
+	schema := `{"provider_schemas": {"registry.terraform.io/hashicorp/azurerm": {"resource_schemas": {
+  "azurerm_resource_group": {"block": {"attributes": {"name": {"required": true}, "location": {"required": true}}}}
+}}}}`
+	if err := os.WriteFile(schemaPath, []byte(schema), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdin := strings.NewReader(`
+resource "azurerm_resource_group" "snippet" {
+  name = "rg-snippet"
+}
+`)
+	result, err := ValidateInput("-", stdin, Options{Logger: t, SchemaFile: schemaPath})
+	if err != nil {
+		t.Fatalf("ValidateInput: %v", err)
+	}
+	if result.Validated != 1 || len(result.Findings) != 1 || result.Findings[0].Name != "location" {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestPreventDestroyPolicy(t *testing.T) {
+	resources := parseFixture(t, `
+resource "azurerm_kubernetes_cluster_node_pool" "ephemeral" {
+  lifecycle {
+    prevent_destroy = true
+  }
+}
+
+resource "azurerm_kubernetes_cluster_node_pool" "allowed" {
+  lifecycle {
+    prevent_destroy = false
+  }
+}
+
+resource "azurerm_key_vault" "protected" {
+  lifecycle {
+    prevent_destroy = true
+  }
+}
+`)
+	opts := &Options{NoPreventDestroyTypes: []string{"azurerm_kubernetes_cluster_node_pool"}}
+
+	var findings []ValidationFinding
+	for _, res := range resources {
+		validatePreventDestroy(t, res, opts, &findings)
+	}
+
+	if len(findings) != 1 || findings[0].ResourceType != "azurerm_kubernetes_cluster_node_pool" || findings[0].Name != "lifecycle" {
+		t.Errorf("expected a single node pool finding, got %+v", findings)
+	}
+}
+
+func TestFindingsFileIsStable(t *testing.T) {
+	findings := []ValidationFinding{
+		{ResourceType: "azurerm_storage_account", Path: "root", Name: "min_tls_version", Kind: FindingMissing},
+		{ResourceType: "azurerm_storage_account", Path: "root.blob_properties", Name: "delete_retention_policy", IsBlock: true, Kind: FindingMissing},
+		{ResourceType: "azurerm_key_vault", Path: "root", Name: "sku_name", Required: true, Kind: FindingMissing},
+		{ResourceType: "azurerm_key_vault", Path: "root", Name: "sku_name", Required: true, Kind: FindingMissing},
+		{ResourceType: "azurerm_key_vault", Path: "root", Name: "depends_on", Kind: FindingInvalid},
+	}
+	shuffled := []ValidationFinding{findings[4], findings[1], findings[3], findings[0], findings[2]}
+
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first.txt"), filepath.Join(dir, "second.txt")
+	if err := WriteFindingsFile(first, findings); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFindingsFile(second, shuffled); err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := os.ReadFile(first)
+	b, _ := os.ReadFile(second)
+	if string(a) != string(b) {
+		t.Fatalf("outputs differ:\n%s\n---\n%s", a, b)
+	}
+
+	want := "azurerm_key_vault root depends_on false property invalid\n" +
+		"azurerm_key_vault root sku_name true property\n" +
+		"azurerm_storage_account blob_properties delete_retention_policy false block\n" +
+		"azurerm_storage_account root min_tls_version false property\n"
+	if string(a) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", a, want)
+	}
+}
+
+func TestProviderLocalNameDiffersFromPrefix(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"terraform.tf": `
+terraform {
+  required_providers {
+    kube = {
+      source = "hashicorp/kubernetes"
+    }
+    azurerm = {
+      source = "hashicorp/azurerm"
+    }
+  }
+}
+`,
+		"main.tf": `
+resource "kubernetes_manifest" "implicit" {}
+
+resource "kubernetes_manifest" "explicit" {
+  provider = kube
+}
+`,
+	})
+	mod, err := ParseModule(&DefaultHCLParser{}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := DecodeSchema([]byte(`{"provider_schemas": {
+  "registry.terraform.io/hashicorp/azurerm": {"resource_schemas": {}},
+  "registry.terraform.io/hashicorp/kubernetes": {"resource_schemas": {
+    "kubernetes_manifest": {"block": {"attributes": {"manifest": {"required": true}}}}
+  }}
+}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := ValidateModule(mod, schema, Options{Logger: t})
+	if result.Validated != 2 {
+		t.Errorf("expected both manifests validated, got %d", result.Validated)
+	}
+	if len(result.Findings) != 2 {
+		t.Errorf("expected a manifest finding per resource, got %+v", result.Findings)
+	}
+}
+
+func TestMixedProvidersRouteToOwningSchema(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"terraform.tf": `
+terraform {
+  required_providers {
+    azurerm = {
+      source  = "hashicorp/azurerm"
+      version = "~> 4.0"
+    }
+    fork = {
+      source  = "contoso/azurerm"
+      version = "~> 1.0"
+    }
+    azuread = {
+      source  = "hashicorp/azuread"
+      version = "~> 3.0"
+    }
+  }
+}
+`,
+		"main.tf": `
+resource "azurerm_resource_group" "upstream" {}
+
+resource "azurerm_resource_group" "forked" {
+  provider = fork
+}
+
+resource "azurerm_preview_widget" "preview" {}
+
+resource "azuread_group" "admins" {}
+`,
+	})
+	mod, err := ParseModule(&DefaultHCLParser{}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := DecodeSchema([]byte(`{"provider_schemas": {
+  "registry.terraform.io/hashicorp/azurerm": {"resource_schemas": {
+    "azurerm_resource_group": {"block": {"attributes": {"location": {"required": true}}}}
+  }},
+  "registry.terraform.io/contoso/azurerm": {"resource_schemas": {
+    "azurerm_resource_group": {"block": {"attributes": {"fork_location": {"required": true}}}},
+    "azurerm_preview_widget": {"block": {"attributes": {"widget": {"required": true}}}}
+  }},
+  "registry.terraform.io/hashicorp/azuread": {"resource_schemas": {
+    "azuread_group": {"block": {"attributes": {"display_name": {"required": true}}}}
+  }}
+}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := ValidateModule(mod, schema, Options{Logger: t})
+
+	if result.Validated != 4 {
+		t.Errorf("expected every resource validated, got %d", result.Validated)
+	}
+	got := make(map[string]int)
+	for _, f := range result.Findings {
+		got[f.ResourceType+"."+f.Name]++
+	}
+	want := map[string]int{
+		"azurerm_resource_group.location":      1,
+		"azurerm_resource_group.fork_location": 1,
+		"azurerm_preview_widget.widget":        1,
+		"azuread_group.display_name":           1,
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got findings %v, want %v", got, want)
+	}
+}
+
+func TestDeprecatedAttributeAllowlist(t *testing.T) {
+	resources := parseFixture(t, `
+resource "azurerm_storage_account" "example" {
+  name                      = "stexample"
+  enable_https_traffic_only = true
+  allow_blob_public_access  = false
+}
+`)
+	schema := schemaFixture(t, `{"attributes": {
+  "name": {"required": true},
+  "enable_https_traffic_only": {"optional": true, "deprecated": true},
+  "allow_blob_public_access": {"optional": true, "deprecated": true}
+}}`)
+	opts := &Options{
+		CheckDeprecated:     true,
+		DeprecatedAllowlist: []string{"azurerm_storage_account:enable_https_traffic_only"},
+	}
+
+	var findings []ValidationFinding
+	resources[0].data.Validate(t, resources[0].Type, "root", schema, nil, opts, &findings)
+
+	if len(findings) != 1 {
+		t.Fatalf("expected one finding, got %+v", findings)
+	}
+	if f := findings[0]; f.Name != "allow_blob_public_access" || f.Message != "attribute is deprecated" {
+		t.Errorf("unexpected finding %+v", f)
+	}
+}
+
+func TestDeprecatedBlock(t *testing.T) {
+	resources := parseFixture(t, `
+resource "azurerm_kubernetes_cluster" "example" {
+  name = "aks-example"
+
+  addon_profile {
+    kube_dashboard {
+      enabled = false
+    }
+  }
+
+  default_node_pool {
+    name = "default"
+  }
+}
+`)
+	schema := schemaFixture(t, `{
+  "attributes": {"name": {"required": true}},
+  "block_types": {
+    "addon_profile": {"nesting": "list", "max_items": 1, "deprecated": true, "block": {"block_types": {
+      "kube_dashboard": {"nesting": "list", "block": {"attributes": {"enabled": {"required": true}, "extra": {"required": true}}}}
+    }}},
+    "default_node_pool": {"nesting": "list", "min_items": 1, "block": {"attributes": {"name": {"required": true}}}}
+  }
+}`)
+
+	var findings []ValidationFinding
+	resources[0].data.Validate(t, resources[0].Type, "root", schema, nil, &Options{CheckDeprecated: true}, &findings)
+
+	if len(findings) != 2 {
+		t.Fatalf("expected two findings, got %+v", findings)
+	}
+	if f, ok := findFinding(findings, "root", "addon_profile"); !ok || !f.IsBlock || f.Message != "uses deprecated block" {
+		t.Errorf("expected deprecated addon_profile finding, got %+v", findings)
+	}
+	// The deprecated block's content is still validated.
+	if _, ok := findFinding(findings, "root.addon_profile.kube_dashboard", "extra"); !ok {
+		t.Errorf("expected nested missing extra finding, got %+v", findings)
+	}
+}
+
+func TestRequireResourceComments(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"terraform.tf": azurermTerraformTf,
+		"main.tf": `
+# Shared resource group for the workload.
+resource "azurerm_resource_group" "documented" {}
+
+/* Block comments count too. */
+resource "azurerm_resource_group" "block" {}
+
+#
+resource "azurerm_resource_group" "empty" {}
+
+# Separated by a blank line.
+
+resource "azurerm_resource_group" "detached" {}
+
+resource "azurerm_resource_group" "bare" {}
+`,
+	})
+	mod, err := ParseModule(&DefaultHCLParser{}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := ValidateModule(mod, &TerraformSchema{}, Options{Logger: t, RequireComments: true})
+
+	var flagged []string
+	for _, f := range result.Findings {
+		if f.Name == "comment" {
+			flagged = append(flagged, f.Message)
+		}
+	}
+	want := []string{
+		"resource empty has no leading comment",
+		"resource detached has no leading comment",
+		"resource bare has no leading comment",
+	}
+	if strings.Join(flagged, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", flagged, want)
+	}
+}
+
+func TestListResourceTypes(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"terraform.tf": azurermTerraformTf,
+		"main.tf": `
+resource "azurerm_resource_group" "a" {}
+resource "azurerm_storage_account" "a" {}
+resource "azurerm_resource_group" "b" {}
+`,
+		"network.tf": `
+resource "azurerm_virtual_network" "a" {}
+resource "azurerm_storage_account" "b" {}
+`,
+	})
+
+	types, err := (&DefaultHCLParser{}).ListResourceTypes(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "azurerm_resource_group,azurerm_storage_account,azurerm_virtual_network"
+	if got := strings.Join(types, ","); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	dup := writeModule(t, map[string]string{
+		"main.tf":    `resource "azurerm_resource_group" "a" {}`,
+		"network.tf": `resource "azurerm_resource_group" "a" {}`,
+	})
+	if _, err := (&DefaultHCLParser{}).ListResourceTypes(dup); err == nil || !strings.Contains(err.Error(), "duplicate resource") {
+		t.Errorf("expected a duplicate resource error, got %v", err)
+	}
+}
+
+func TestDynamicBlockForEachLength(t *testing.T) {
+	resources := parseFixture(t, `
+resource "azurerm_kubernetes_cluster" "too_many" {
+  dynamic "default_node_pool" {
+    for_each = [local.a, local.b, local.c]
+    content {
+      name = default_node_pool.value
+    }
+  }
+}
+
+resource "azurerm_kubernetes_cluster" "one" {
+  dynamic "default_node_pool" {
+    for_each = { system = "a" }
+    content {
+      name = default_node_pool.value
+    }
+  }
+}
+
+resource "azurerm_kubernetes_cluster" "unknown" {
+  dynamic "default_node_pool" {
+    for_each = var.pools
+    content {
+      name = default_node_pool.value
+    }
+  }
+}
+`)
+	schema := schemaFixture(t, `{
+  "block_types": {
+    "default_node_pool": {
+      "nesting": "list",
+      "min_items": 1,
+      "max_items": 1,
+      "block": {"attributes": {"name": {"required": true}}}
+    }
+  }
+}`)
+
+	for i, want := range []string{"dynamic block for_each yields 3 blocks, exceeds max_items 1", "", ""} {
+		var findings []ValidationFinding
+		resources[i].data.Validate(t, resources[i].Type, "root", schema, nil, nil, &findings)
+
+		f, _ := findFinding(findings, "root", "default_node_pool")
+		if got := f.Message; got != want {
+			t.Errorf("%s: got %q, want %q", resources[i].Name, got, want)
+		}
+	}
+}
+
+func TestCountAndForEachConflict(t *testing.T) {
+	resources := parseFixture(t, `
+resource "azurerm_resource_group" "both" {
+  count    = 2
+  for_each = toset(["a", "b"])
+}
+
+resource "azurerm_resource_group" "count" {
+  count = 2
+}
+
+resource "azurerm_resource_group" "for_each" {
+  for_each = toset(["a", "b"])
+}
+`)
+
+	for i, want := range []int{1, 0, 0} {
+		var findings []ValidationFinding
+		validateRepetition(t, resources[i], &findings)
+		if len(findings) != want {
+			t.Errorf("%s: expected %d findings, got %+v", resources[i].Name, want, findings)
+		}
+	}
+}
+
+func TestRequiredBlockOverlay(t *testing.T) {
+	resources := parseFixture(t, `
+resource "azurerm_linux_virtual_machine" "example" {
+  name = "vm-example"
+}
+`)
+	schema := schemaFixture(t, `{
+  "attributes": {"name": {"required": true}},
+  "block_types": {
+    "identity": {"nesting": "list", "max_items": 1, "block": {}},
+    "boot_diagnostics": {"nesting": "list", "max_items": 1, "block": {}}
+  }
+}`)
+	opts := &Options{RequiredBlocks: map[string][]string{
+		"azurerm_linux_virtual_machine": {"identity"},
+	}}
+
+	var findings []ValidationFinding
+	resources[0].data.Validate(t, resources[0].Type, "root", schema, nil, opts, &findings)
+
+	if f, ok := findFinding(findings, "root", "identity"); !ok || !f.Required || !f.IsBlock {
+		t.Errorf("expected identity to be a required block, got %+v", findings)
+	}
+	if f, ok := findFinding(findings, "root", "boot_diagnostics"); !ok || f.Required {
+		t.Errorf("expected boot_diagnostics to stay optional, got %+v", findings)
+	}
+}
+
+func TestCloudBlockRequiresOrganization(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"terraform.tf": `
+terraform {
+  cloud {
+    workspaces {
+      name = "platform"
+    }
+  }
+
+  required_providers {
+    azurerm = {
+      source = "hashicorp/azurerm"
+    }
+  }
+}
+`,
+		"main.tf": `resource "azurerm_resource_group" "example" {}`,
+	})
+	mod, err := ParseModule(&DefaultHCLParser{}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mod.Cloud == nil {
+		t.Fatal("expected cloud block to be parsed")
+	}
+
+	result := ValidateModule(mod, &TerraformSchema{}, Options{Logger: t})
+
+	if len(result.Findings) != 1 {
+		t.Fatalf("expected one finding, got %+v", result.Findings)
+	}
+	if f := result.Findings[0]; f.ResourceType != "terraform.cloud" || f.Name != "organization" || !f.Required {
+		t.Errorf("unexpected finding %+v", f)
+	}
+}
+
+func TestBadgeFile(t *testing.T) {
+	optional := ValidationFinding{ResourceType: "azurerm_x", Path: "root", Name: "tags", Kind: FindingMissing}
+	required := ValidationFinding{ResourceType: "azurerm_x", Path: "root", Name: "location", Required: true, Kind: FindingMissing}
+
+	for _, tc := range []struct {
+		findings []ValidationFinding
+		message  string
+		color    string
+	}{
+		{nil, "0", "green"},
+		{[]ValidationFinding{optional, optional}, "2", "yellow"},
+		{[]ValidationFinding{optional, required, optional}, "3", "red"},
+	} {
+		path := filepath.Join(t.TempDir(), "badge.json")
+		if err := WriteBadgeFile(path, tc.findings); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var badge map[string]any
+		if err := json.Unmarshal(data, &badge); err != nil {
+			t.Fatal(err)
+		}
+		if badge["schemaVersion"] != float64(1) || badge["label"] != "schema findings" ||
+			badge["message"] != tc.message || badge["color"] != tc.color {
+			t.Errorf("unexpected badge %s", data)
+		}
+	}
+}
+
+func TestPromTextfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gophx.prom")
+	err := WritePromTextfile(path, []ValidationFinding{
+		{ResourceType: "azurerm_storage_account", Path: "root", Name: "min_tls_version", Kind: FindingMissing},
+		{ResourceType: "azurerm_storage_account", Path: "root", Name: "location", Required: true, Kind: FindingMissing},
+		{ResourceType: "azurerm_storage_account", Path: "root", Name: "tags", Kind: FindingMissing},
+		{ResourceType: "azurerm_resource_group", Path: "root", Name: "location", Required: true, Kind: FindingMissing},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sample := regexp.MustCompile(`^gophx_findings\{resource_type="([^"\\]*)",required="(true|false)"\} (\d+)$`)
+	got := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		if strings.HasPrefix(line, "# HELP gophx_findings ") || line == "# TYPE gophx_findings gauge" {
+			continue
+		}
+		m := sample.FindStringSubmatch(line)
+		if m == nil {
+			t.Fatalf("unparseable line %q", line)
+		}
+		got[m[1]+"/"+m[2]] = m[3]
+	}
+
+	want := map[string]string{
+		"azurerm_resource_group/true":   "1",
+		"azurerm_storage_account/false": "2",
+		"azurerm_storage_account/true":  "1",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got series %v, want %v", got, want)
+	}
+}
+
+func TestDataReferencesUseDeclaredDataBlocks(t *testing.T) {
+	mod := moduleFixture(t, `
+data "azurerm_client_config" "current" {}
+
+resource "azurerm_key_vault" "example" {
+  tenant_id = data.azurerm_client_config.current.tenant_id
+
+  access_policy {
+    object_id = data.azurerm_client_config.curent.object_id
+  }
+}
+
+resource "azurerm_role_assignment" "example" {
+  principal_id = data.azuread_group.admins.object_id
+  scope        = azurerm_key_vault.example.id
+}
+`)
+
+	result := ValidateModule(mod, &TerraformSchema{}, Options{Logger: t})
+
+	want := map[string]string{
+		"root.access_policy/object_id": "references undeclared data.azurerm_client_config.curent",
+		"root/principal_id":            "references undeclared data.azuread_group.admins",
+	}
+	if len(result.Findings) != len(want) {
+		t.Fatalf("expected %d findings, got %+v", len(want), result.Findings)
+	}
+	for _, f := range result.Findings {
+		if want[f.Path+"/"+f.Name] != f.Message {
+			t.Errorf("unexpected finding %+v", f)
+		}
+	}
+}
+
+type recordLogger struct {
+	lines []string
+}
+
+func (r *recordLogger) Logf(format string, args ...any) {
+	r.lines = append(r.lines, fmt.Sprintf(format, args...))
+}
+
+func TestRequiredOnlySuppressesOptionalFindings(t *testing.T) {
+	mod := moduleFixture(t, `
+resource "azurerm_storage_account" "example" {
+  name                      = "stexample"
+  enable_https_traffic_only = true
+}
+`)
+	schema := providerSchemaFixture(t, `{"azurerm_storage_account": {"block": {
+  "attributes": {
+    "name": {"required": true},
+    "location": {"required": true},
+    "tags": {"optional": true},
+    "enable_https_traffic_only": {"optional": true, "deprecated": true}
+  },
+  "block_types": {
+    "network_rules": {"nesting": "list", "max_items": 1, "block": {}}
+  }
+}}}`)
+
+	log := &recordLogger{}
+	result := ValidateModule(mod, schema, Options{Logger: log, RequiredOnly: true, CheckDeprecated: true})
+
+	if len(result.Findings) != 1 || result.Findings[0].Name != "location" {
+		t.Errorf("expected only the required location finding, got %+v", result.Findings)
+	}
+	for _, line := range log.lines {
+		if strings.Contains(line, "optional") || strings.Contains(line, "deprecated") {
+			t.Errorf("optional finding was logged: %s", line)
+		}
+	}
+}
+
+func TestOutputReferencesDeclaredAddresses(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"terraform.tf": azurermTerraformTf,
+		"main.tf": `
+resource "azurerm_resource_group" "example" {}
+
+module "network" {
+  source = "./modules/network"
+}
+`,
+		"outputs.tf": `
+output "resource_group_id" {
+  value = azurerm_resource_group.example.id
+}
+
+output "subnet_ids" {
+  value = module.network.subnet_ids
+}
+
+output "vnet_id" {
+  value = azurerm_virtual_network.example.id
+}
+
+output "location" {
+  value = var.location
+}
+`,
+	})
+	mod, err := ParseModule(&DefaultHCLParser{}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := ValidateModule(mod, &TerraformSchema{}, Options{Logger: t})
+
+	if len(result.Findings) != 1 {
+		t.Fatalf("expected one finding, got %+v", result.Findings)
+	}
+	if f := result.Findings[0]; f.Name != "vnet_id" || f.Message != "references undeclared azurerm_virtual_network.example" {
+		t.Errorf("unexpected finding %+v", f)
+	}
+}
+
+func TestWatchRerunsOnFileChange(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"terraform.tf": azurermTerraformTf,
+		"main.tf":      `resource "azurerm_resource_group" "example" {}`,
+		"schema.json": `{"provider_schemas": {"registry.terraform.io/hashicorp/azurerm": {"resource_schemas": {
+  "azurerm_resource_group": {"block": {"attributes": {"location": {"required": true}}}}
+}}}}`,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan string)
+	runs := make(chan Result, 4)
+	done := make(chan error, 1)
+	go func() {
+		done <- Watch(ctx, dir, events, 20*time.Millisecond, Options{SchemaFile: filepath.Join(dir, "schema.json")}, func(result Result, err error) {
+			if err != nil {
+				t.Errorf("run failed: %v", err)
+			}
+			runs <- result
+		})
+	}()
+
+	if first := <-runs; len(first.Findings) != 1 {
+		t.Fatalf("expected the missing location on the first run, got %+v", first.Findings)
+	}
+
+	fixed := `resource "azurerm_resource_group" "example" { location = "westeurope" }`
+	if err := os.WriteFile(filepath.Join(dir, "main.tf"), []byte(fixed), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"main.tf", "notes.md", "main.tf", "main.tf"} {
+		events <- filepath.Join(dir, path)
+	}
+
+	select {
+	case second := <-runs:
+		if len(second.Findings) != 0 {
+			t.Errorf("expected the re-run to see the fix, got %+v", second.Findings)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("validation did not re-run")
+	}
+
+	close(events)
+	if err := <-done; err != nil {
+		t.Errorf("Watch: %v", err)
+	}
+	if extra := len(runs); extra != 0 {
+		t.Errorf("expected the burst to debounce into one run, got %d more", extra)
+	}
+}
+
+func TestNestedMaxItemsAtDepth(t *testing.T) {
+	resources := parseFixture(t, `
+resource "azurerm_kubernetes_cluster" "example" {
+  default_node_pool {
+    upgrade_settings {
+      drain {
+        timeout = 30
+      }
+      drain {
+        timeout = 60
+      }
+    }
+  }
+}
+`)
+	schema := schemaFixture(t, `{
+  "block_types": {
+    "default_node_pool": {"nesting": "list", "max_items": 1, "block": {
+      "block_types": {
+        "upgrade_settings": {"nesting": "list", "max_items": 1, "block": {
+          "block_types": {
+            "drain": {"nesting": "list", "max_items": 1, "block": {"attributes": {"timeout": {"optional": true}}}}
+          }
+        }}
+      }
+    }}
+  }
+}`)
+
+	var findings []ValidationFinding
+	resources[0].data.Validate(t, resources[0].Type, "root", schema, nil, nil, &findings)
+
+	if len(findings) != 1 {
+		t.Fatalf("expected one finding, got %+v", findings)
+	}
+	if f := findings[0]; f.Path != "root.default_node_pool.upgrade_settings" || f.Name != "drain" || f.Message != "2 blocks, exceeds max_items 1" {
+		t.Errorf("unexpected finding %+v", f)
+	}
+}
+
+func TestValidationSummaryCounts(t *testing.T) {
+	mod := moduleFixture(t, `
+resource "azurerm_resource_group" "a" {}
+resource "azurerm_resource_group" "b" {}
+resource "azurerm_role_assignment" "unknown" {}
+resource "azurerm_key_vault" "excluded" {}
+resource "random_string" "suffix" {}
+resource "azurerm_client_config" "current" {}
+`)
+	schema := providerSchemaFixture(t, `{
+  "azurerm_resource_group": {"block": {"attributes": {"location": {"required": true}}}},
+  "azurerm_client_config": {"block": {"attributes": {"tenant_id": {"computed": true}, "object_id": {"computed": true}}}}
+}`)
+
+	result := ValidateModule(mod, schema, Options{Logger: t, SkipTypes: []string{"azurerm_key_vault"}})
+
+	if result.Resources != 6 || result.Validated != 2 {
+		t.Errorf("expected 2 of 6 validated, got %d of %d", result.Validated, result.Resources)
+	}
+	want := map[SkipReason]int{SkipExcluded: 1, SkipNoProvider: 1, SkipNoSchema: 1, SkipComputedOnly: 1}
+	for reason, n := range want {
+		if result.Skipped[reason] != n {
+			t.Errorf("skipped %s: got %d, want %d", reason, result.Skipped[reason], n)
+		}
+	}
+}
+
+func TestMovedBlockTargets(t *testing.T) {
+	mod := moduleFixture(t, `
+resource "azurerm_resource_group" "main" {}
+resource "azurerm_resource_group" "legacy" {}
+
+moved {
+  from = azurerm_resource_group.old
+  to   = azurerm_resource_group.main
+}
+
+moved {
+  from = azurerm_resource_group.previous
+  to   = azurerm_resource_group.mian
+}
+
+moved {
+  from = azurerm_resource_group.legacy
+  to   = azurerm_resource_group.main
+}
+`)
+
+	result := ValidateModule(mod, &TerraformSchema{}, Options{Logger: t})
+
+	want := []string{
+		"to: moved from azurerm_resource_group.previous to undeclared azurerm_resource_group.mian",
+		"from: moved from azurerm_resource_group.legacy, which is still declared",
+	}
+	var got []string
+	for _, f := range result.Findings {
+		got = append(got, f.Name+": "+f.Message)
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestParseModuleReadsEveryFile(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"main.tf": `resource "azurerm_resource_group" "main" {}`,
+		"versions.tf": `
+terraform {
+  required_version = ">= 1.5"
+  required_providers {
+    azurerm = {
+      source = "hashicorp/azurerm"
+    }
+  }
+}
+`,
+		"providers.tf": `
+provider "azurerm" {
+  features {}
+}
+`,
+		"exports.tf": `
+output "admin_password" {
+  value = random_password.admin.result
+}
+`,
+		"refactor.tf": `
+moved {
+  from = azurerm_resource_group.old
+  to   = azurerm_resource_group.main
+}
+`,
+	})
+	mod, err := ParseModule(&DefaultHCLParser{}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mod.Providers["azurerm"].Source != "registry.terraform.io/hashicorp/azurerm" {
+		t.Errorf("provider requirements not read from versions.tf: %+v", mod.Providers)
+	}
+	if mod.RequiredVersion != ">= 1.5" {
+		t.Errorf("required_version not read from versions.tf: %q", mod.RequiredVersion)
+	}
+	if len(mod.ProviderBlocks) != 1 {
+		t.Errorf("provider block not read from providers.tf: %+v", mod.ProviderBlocks)
+	}
+	if len(mod.Outputs) != 1 || mod.Outputs[0].Name != "admin_password" {
+		t.Errorf("outputs not read from exports.tf: %+v", mod.Outputs)
+	}
+	if len(mod.Moved) != 1 {
+		t.Errorf("moved block not read from refactor.tf: %+v", mod.Moved)
+	}
+}
+
+func TestProviderAttributesFromVariables(t *testing.T) {
+	mod := moduleFixture(t, `
+provider "azurerm" {
+  subscription_id = var.subscription_id
+  environment     = var.environment
+  features {}
+}
+
+provider "azurerm" {
+  alias           = "china"
+  subscription_id = "00000000-0000-0000-0000-000000000000"
+  environment     = "chinaa"
+  features {}
+}
+`)
+	mod.Vars["environment"] = cty.StringVal("invalid")
+	schema, err := DecodeSchema([]byte(`{"provider_schemas": {"registry.terraform.io/hashicorp/azurerm": {
+  "provider": {"block": {
+    "attributes": {"subscription_id": {"required": true}, "environment": {"optional": true}},
+    "block_types": {"features": {"nesting": "list", "min_items": 1, "max_items": 1, "block": {}}}
+  }}
+}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{Logger: t, AllowedValues: map[string]map[string][]string{
+		"provider.azurerm": {"environment": {"public", "usgovernment", "china"}},
+	}}
+
+	result := ValidateModule(mod, schema, opts)
+
+	if len(result.Findings) != 1 {
+		t.Fatalf("expected only the literal environment to be flagged, got %+v", result.Findings)
+	}
+	if f := result.Findings[0]; f.Name != "environment" || !strings.Contains(f.Message, `"chinaa"`) {
+		t.Errorf("unexpected finding %+v", f)
+	}
+}
+
+func TestUnusedVariables(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"terraform.tf": azurermTerraformTf,
+		"variables.tf": `
+variable "location" {}
+variable "prefix" {}
+variable "subnet_count" {}
+variable "unused" {
+  validation {
+    condition     = length(var.unused) > 0
+    error_message = "must not be empty"
+  }
+}
+`,
+		"main.tf": `
+locals {
+  name = "${var.prefix}-rg"
+}
+
+resource "azurerm_resource_group" "example" {
+  name     = local.name
+  location = var.location
+}
+
+module "network" {
+  source       = "./modules/network"
+  subnet_count = var.subnet_count
+}
+`,
+	})
+	mod, err := ParseModule(&DefaultHCLParser{}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := ValidateModule(mod, &TerraformSchema{}, Options{Logger: t})
+
+	var unused []string
+	for _, f := range result.Findings {
+		if f.ResourceType == "variable" {
+			unused = append(unused, f.Name)
+		}
+	}
+	if strings.Join(unused, ",") != "unused" {
+		t.Errorf("expected only unused to be flagged, got %v", unused)
+	}
+}
+
+func TestFmtCheckWithStubBinary(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"terraform.tf": azurermTerraformTf,
+		"main.tf":      `resource "azurerm_resource_group" "example" {}`,
+		"schema.json":  `{"provider_schemas": {"registry.terraform.io/hashicorp/azurerm": {"resource_schemas": {}}}}`,
+		"bin/terraform": `#!/bin/sh
+case "$*" in
+  *-diff*) echo "--- old/main.tf"; exit 3 ;;
+  *-list=true*) echo "main.tf"; echo "network.tf"; exit 3 ;;
+esac
+exit 1
+`,
+	})
+	stub := filepath.Join(dir, "bin", "terraform")
+	if err := os.Chmod(stub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TERRAFORM_BIN", stub)
+
+	result, err := RunValidation(dir, Options{Logger: t, SchemaFile: filepath.Join(dir, "schema.json"), FmtCheck: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var files []string
+	for _, f := range result.Findings {
+		if f.ResourceType == "fmt" {
+			files = append(files, f.Name)
+		}
+	}
+	if strings.Join(files, ",") != "main.tf,network.tf" {
+		t.Errorf("expected both files flagged, got %v", files)
+	}
+}
+
+func TestOutputsExposingSensitiveSchemaAttributes(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"terraform.tf": azurermTerraformTf,
+		"main.tf": `
+resource "azurerm_kubernetes_cluster" "example" {}
+`,
+		"outputs.tf": `
+output "kubeconfig" {
+  value = azurerm_kubernetes_cluster.example.kube_config_raw
+}
+
+output "kubeconfig_marked" {
+  value     = azurerm_kubernetes_cluster.example.kube_config_raw
+  sensitive = true
+}
+
+output "fqdn" {
+  value = azurerm_kubernetes_cluster.example.fqdn
+}
+`,
+	})
+	mod, err := ParseModule(&DefaultHCLParser{}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := providerSchemaFixture(t, `{"azurerm_kubernetes_cluster": {"block": {"attributes": {
+  "kube_config_raw": {"computed": true, "sensitive": true},
+  "fqdn": {"computed": true}
+}}}}`)
+
+	result := ValidateModule(mod, schema, Options{Logger: t})
+
+	var flagged []string
+	for _, f := range result.Findings {
+		if f.ResourceType == "output" {
+			flagged = append(flagged, f.Name+": "+f.Message)
+		}
+	}
+	want := "kubeconfig: output exposes sensitive attribute azurerm_kubernetes_cluster.example.kube_config_raw but is not marked sensitive"
+	if strings.Join(flagged, "\n") != want {
+		t.Errorf("got %q, want %q", flagged, want)
+	}
+}
+
+func TestRequiredBlockSatisfaction(t *testing.T) {
+	resources := parseFixture(t, `
+resource "azurerm_linux_virtual_machine" "static" {
+  os_disk {
+    caching = "ReadWrite"
+  }
+}
+
+resource "azurerm_linux_virtual_machine" "static_and_empty" {
+  os_disk {
+    caching = "ReadWrite"
+  }
+  dynamic "os_disk" {
+    for_each = []
+    content {}
+  }
+}
+
+resource "azurerm_linux_virtual_machine" "dynamic_empty" {
+  dynamic "os_disk" {
+    for_each = false ? ["disk"] : []
+    content {}
+  }
+}
+
+resource "azurerm_linux_virtual_machine" "dynamic_conditional" {
+  dynamic "os_disk" {
+    for_each = var.managed_disk ? ["disk"] : []
+    content {
+      caching = "ReadWrite"
+    }
+  }
+}
+`)
+	schema := schemaFixture(t, `{"block_types": {
+  "os_disk": {"nesting": "list", "min_items": 1, "max_items": 1, "block": {"attributes": {"caching": {"required": true}}}}
+}}`)
+
+	want := map[string]string{
+		"static":              "",
+		"static_and_empty":    "",
+		"dynamic_empty":       "root/os_disk: dynamic block for_each is always empty",
+		"dynamic_conditional": "",
+	}
+	for _, res := range resources {
+		var findings []ValidationFinding
+		res.data.Validate(t, res.Type, "root", schema, nil, nil, &findings)
+
+		var got []string
+		for _, f := range findings {
+			got = append(got, f.Path+"/"+f.Name+": "+f.Message)
+		}
+		if strings.Join(got, "\n") != want[res.Name] {
+			t.Errorf("%s: got %q, want %q", res.Name, got, want[res.Name])
+		}
+	}
+}
+
+func TestProviderVersionRanges(t *testing.T) {
+	pins := map[string]string{
+		"in_pessimistic": "~> 4.0",
+		"in_range":       ">= 3.5, < 4.8",
+		"in_excluding":   "~> 4.1, != 4.3.0",
+		"in_major":       "~> 3",
+		"below_min":      ">= 2.0, < 4.0",
+		"below_patch":    "~> 2.9",
+		"above_open":     ">= 3.0",
+		"above_exact":    "5.0.0",
+		"invalid":        "latest",
+	}
+	providers := make(map[string]ProviderConfig, len(pins))
+	ranges := make(map[string]string, len(pins))
+	for name, pin := range pins {
+		providers[name] = ProviderConfig{Source: "registry.terraform.io/hashicorp/azurerm", Version: pin}
+		ranges[name] = ">= 3.0, < 5.0"
+	}
+
+	var findings []ValidationFinding
+	validateProviderVersions(t, providers, &Options{ProviderVersionRanges: ranges}, &findings)
+
+	got := make(map[string]string)
+	for _, f := range findings {
+		got[f.Name] = f.Message
+	}
+	want := map[string]string{
+		"below_min":   `version constraint ">= 2.0, < 4.0" allows versions below ">= 3.0, < 5.0"`,
+		"below_patch": `version constraint "~> 2.9" allows versions below ">= 3.0, < 5.0"`,
+		"above_open":  `version constraint ">= 3.0" allows versions above ">= 3.0, < 5.0"`,
+		"above_exact": `version constraint "5.0.0" allows versions above ">= 3.0, < 5.0"`,
+	}
+	for name, msg := range want {
+		if got[name] != msg {
+			t.Errorf("%s: got %q, want %q", name, got[name], msg)
+		}
+	}
+	if !strings.HasPrefix(got["invalid"], `invalid version constraint "latest"`) {
+		t.Errorf("invalid: got %q", got["invalid"])
+	}
+	if len(got) != len(want)+1 {
+		t.Errorf("unexpected findings %+v", findings)
+	}
+}
+
+func TestTerraformVersionFile(t *testing.T) {
+	terraformTf := `
+terraform {
+  required_version = "~> 1.5"
+
+  required_providers {
+    azurerm = {
+      source  = "hashicorp/azurerm"
+      version = "~> 4.0"
+    }
+  }
+}
+`
+	for _, tc := range []struct {
+		name, file, content, message string
+	}{
+		{"tfenv match", ".terraform-version", "1.9.8\n", ""},
+		{"tfenv mismatch", ".terraform-version", "2.0.0\n", `pinned version 2.0.0 does not satisfy required_version "~> 1.5"`},
+		{"asdf match", ".tool-versions", "golang 1.23.4\nterraform 1.5.7\n", ""},
+		{"asdf mismatch", ".tool-versions", "terraform 1.4.6\n", `pinned version 1.4.6 does not satisfy required_version "~> 1.5"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := writeModule(t, map[string]string{
+				".git/HEAD":    "ref: refs/heads/main\n",
+				"terraform.tf": terraformTf,
+				"main.tf":      `resource "azurerm_resource_group" "example" {}`,
+				tc.file:        tc.content,
+			})
+			mod, err := ParseModule(&DefaultHCLParser{}, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result := ValidateModule(mod, &TerraformSchema{}, Options{Logger: t})
+
+			if tc.message == "" {
+				if len(result.Findings) != 0 {
+					t.Fatalf("expected no findings, got %+v", result.Findings)
+				}
+				return
+			}
+			if len(result.Findings) != 1 {
+				t.Fatalf("expected one finding, got %+v", result.Findings)
+			}
+			if f := result.Findings[0]; f.Name != tc.file || f.Message != tc.message {
+				t.Errorf("unexpected finding %+v", f)
+			}
+		})
+	}
+}
+
+func TestPlaintextProviderCredentials(t *testing.T) {
+	mod := moduleFixture(t, `
+provider "azurerm" {
+  features {}
+  client_id     = "00000000-0000-0000-0000-000000000000"
+  client_secret = "s3cr3t"
+}
+
+provider "azurerm" {
+  alias         = "secondary"
+  features {}
+  client_secret = var.client_secret
+}
+
+resource "azurerm_resource_group" "example" {}
+`)
+
+	result := ValidateModule(mod, &TerraformSchema{}, Options{Logger: t})
+
+	if len(result.Findings) != 1 {
+		t.Fatalf("expected one finding, got %+v", result.Findings)
+	}
+	if f := result.Findings[0]; f.ResourceType != "provider.azurerm" || f.Name != "client_secret" || f.Message != "credential is set to a literal value" {
+		t.Errorf("unexpected finding %+v", f)
+	}
+
+	result = ValidateModule(mod, &TerraformSchema{}, Options{Logger: t, CredentialAttributes: map[string][]string{"azurerm": {"client_id"}}})
+	if len(result.Findings) != 1 || result.Findings[0].Name != "client_id" {
+		t.Errorf("expected configured client_id finding, got %+v", result.Findings)
+	}
+}
+
+func TestUnpinnedProviders(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"terraform.tf": `
+terraform {
+  required_providers {
+    azurerm = {
+      source  = "hashicorp/azurerm"
+      version = "~> 4.0"
+    }
+    random = {
+      source = "hashicorp/random"
+    }
+  }
+}
+`,
+		"main.tf": `resource "azurerm_resource_group" "example" {}`,
+	})
+	mod, err := ParseModule(&DefaultHCLParser{}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result := ValidateModule(mod, &TerraformSchema{}, Options{Logger: t}); len(result.Findings) != 0 {
+		t.Fatalf("expected no findings without the option, got %+v", result.Findings)
+	}
+
+	result := ValidateModule(mod, &TerraformSchema{}, Options{Logger: t, RequireProviderVersions: true})
+	if len(result.Findings) != 1 {
+		t.Fatalf("expected one finding, got %+v", result.Findings)
+	}
+	if f := result.Findings[0]; f.ResourceType != "terraform.required_providers" || f.Name != "random" || f.Message != "no version constraint" {
+		t.Errorf("unexpected finding %+v", f)
+	}
+}
+
+func TestIndexedNamesForMultiInstanceResources(t *testing.T) {
+	resources := parseFixture(t, `
+resource "azurerm_resource_group" "counted" {
+  count    = 2
+  name     = "rg-${count.index}"
+  location = "westeurope"
+}
+
+resource "azurerm_resource_group" "keyed" {
+  for_each = toset(["a", "b"])
+  name     = "rg-shared"
+  tags = {
+    instance = each.key
+  }
+}
+
+resource "azurerm_resource_group" "collides" {
+  count = 2
+  name  = "rg-shared"
+  tags  = { env = "dev" }
+}
+
+resource "azurerm_resource_group" "single" {
+  name = "rg-single"
+}
+`)
+	opts := &Options{RequireIndexedNames: true}
+
+	var findings []ValidationFinding
+	for _, res := range resources {
+		validateIndexedNames(t, res, opts, &findings)
+	}
+
+	if len(findings) != 1 {
+		t.Fatalf("expected one finding, got %+v", findings)
+	}
+	if f := findings[0]; f.Name != "name" || f.Message != "count resource does not reference its instance key in name, tags" {
+		t.Errorf("unexpected finding %+v", f)
+	}
+}
+
+func TestRecordFindingsAsSpanEvents(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	RecordFindings(context.Background(), tp.Tracer("test"), nil, []ValidationFinding{
+		{ResourceType: "azurerm_resource_group", Path: "root", Name: "location", Required: true, Kind: FindingMissing},
+		{ResourceType: "azurerm_storage_account", Path: "root.blob_properties", Name: "versioning_enabled", Kind: FindingMissing},
+	})
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Name() != "gophx.validate" {
+		t.Fatalf("expected one gophx.validate span, got %d", len(spans))
+	}
+	events := spans[0].Events()
+	if len(events) != 2 {
+		t.Fatalf("expected two events, got %d", len(events))
+	}
+
+	got := make(map[attribute.Key]attribute.Value)
+	for _, kv := range events[0].Attributes {
+		got[kv.Key] = kv.Value
+	}
+	if got["resource_type"].AsString() != "azurerm_resource_group" || got["path"].AsString() != "root" ||
+		got["name"].AsString() != "location" || !got["required"].AsBool() {
+		t.Errorf("unexpected event attributes %v", events[0].Attributes)
+	}
+}
+
+func TestParseModuleFilesAcrossFiles(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"terraform.tf": azurermTerraformTf,
+		"main.tf":      `resource "azurerm_resource_group" "example" {}`,
+		"network.tf":   `resource "azurerm_virtual_network" "example" {}`,
+	})
+	mod, err := ParseModule(&DefaultHCLParser{}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := providerSchemaFixture(t, `{
+  "azurerm_resource_group": {"block": {"attributes": {"location": {"required": true}}}},
+  "azurerm_virtual_network": {"block": {"attributes": {"address_space": {"required": true}}}}
+}`)
+
+	result := ValidateModule(mod, schema, Options{Logger: t})
+
+	if result.Validated != 2 {
+		t.Errorf("expected resources from both files validated, got %d", result.Validated)
+	}
+	if _, ok := findFinding(result.Findings, "root", "address_space"); !ok {
+		t.Errorf("expected network.tf resource to be validated, got %+v", result.Findings)
+	}
+	if !mod.Declared["azurerm_virtual_network.example"] {
+		t.Errorf("expected network.tf address to be declared")
+	}
+}
+
+func TestParseModuleFilesDuplicateAddress(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"main.tf":    `resource "azurerm_resource_group" "example" {}`,
+		"storage.tf": `resource "azurerm_resource_group" "example" {}`,
+	})
+	_, err := (&DefaultHCLParser{}).ParseModuleFiles(dir)
+	if err == nil || !strings.Contains(err.Error(), "duplicate resource azurerm_resource_group.example") {
+		t.Fatalf("expected duplicate resource error, got %v", err)
+	}
+}
+
+func TestDataSourceRequiredArguments(t *testing.T) {
+	mod := moduleFixture(t, `
+data "azurerm_key_vault_secret" "admin" {
+  key_vault_id = "kv"
+}
+
+data "azurerm_client_config" "current" {}
+
+output "vault_entry" {
+  value = data.azurerm_key_vault_secret.admin.value
+}
+`)
+	schema, err := DecodeSchema([]byte(`{"provider_schemas": {"registry.terraform.io/hashicorp/azurerm": {
+  "resource_schemas": {},
+  "data_source_schemas": {
+    "azurerm_key_vault_secret": {"block": {"attributes": {
+      "name": {"required": true},
+      "key_vault_id": {"required": true},
+      "value": {"computed": true, "sensitive": true}
+    }}},
+    "azurerm_client_config": {"block": {"attributes": {"tenant_id": {"computed": true}}}}
+  }
+}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := ValidateModule(mod, schema, Options{Logger: t})
+
+	if result.Validated != 1 || result.Skipped[SkipComputedOnly] != 1 {
+		t.Errorf("expected one data source validated and the computed-only one skipped, got %d and %v", result.Validated, result.Skipped)
+	}
+	f, ok := findFinding(result.Findings, "root", "name")
+	if !ok || f.ResourceType != "data.azurerm_key_vault_secret" || f.Kind != FindingMissing {
+		t.Errorf("expected missing name on the data source, got %+v", result.Findings)
+	}
+	if f, ok := findFinding(result.Findings, "root", "vault_entry"); !ok || !strings.Contains(f.Message, "data.azurerm_key_vault_secret.admin.value") {
+		t.Errorf("expected sensitive data source attribute output flagged, got %+v", result.Findings)
+	}
+	if len(result.Findings) != 2 {
+		t.Errorf("expected 2 findings, got %+v", result.Findings)
+	}
+}
+
+func TestChildModulesValidated(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"terraform.tf": azurermTerraformTf,
+		"main.tf": `
+resource "azurerm_resource_group" "example" {
+  location = "westeurope"
+}
+
+module "storage" {
+  source = "./modules/storage"
+}
+
+module "naming" {
+  source  = "Azure/naming/azurerm"
+  version = "0.4.0"
+}
+`,
+		"modules/storage/main.tf": `
+resource "azurerm_resource_group" "inner" {}
+
+module "nested" {
+  source = "../nested"
+}
+`,
+		"modules/nested/main.tf": `resource "azurerm_resource_group" "deep" {}`,
+	})
+	mod, err := ParseModule(&DefaultHCLParser{}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := providerSchemaFixture(t, `{"azurerm_resource_group": {"block": {"attributes": {"location": {"required": true}}}}}`)
+
+	result := ValidateModule(mod, schema, Options{Logger: t})
+
+	if result.Validated != 3 || result.Resources != 3 {
+		t.Errorf("expected 3 resources validated across modules, got %d of %d", result.Validated, result.Resources)
+	}
+	var paths []string
+	for _, f := range result.Findings {
+		paths = append(paths, f.Path)
+	}
+	if strings.Join(paths, ",") != "module.storage.root,module.storage.module.nested.root" {
+		t.Errorf("unexpected finding paths %v", paths)
+	}
+}
+
+func TestUnknownAttributes(t *testing.T) {
+	mod := moduleFixture(t, `
+resource "azurerm_storage_account" "example" {
+  count    = 1
+  name     = "st"
+  locaton  = "westeurope"
+
+  network_rules {
+    default_action = "Deny"
+    bypas          = ["AzureServices"]
+  }
+}
+`)
+	schema := providerSchemaFixture(t, `{"azurerm_storage_account": {"block": {
+  "attributes": {"name": {"required": true}, "location": {"optional": true}},
+  "block_types": {"network_rules": {"nesting_mode": "list", "block": {"attributes": {
+    "default_action": {"required": true},
+    "bypass": {"optional": true}
+  }}}}
+}}}`)
+
+	if result := ValidateModule(mod, schema, Options{Logger: t}); len(result.Findings) != 2 {
+		t.Errorf("expected only missing optional attributes without the flag, got %+v", result.Findings)
+	}
+
+	result := ValidateModule(mod, schema, Options{Logger: t, CheckUnknownAttributes: true})
+	var unexpected []string
+	for _, f := range result.Findings {
+		if f.Kind == FindingUnexpected {
+			unexpected = append(unexpected, f.Path+"."+f.Name)
+		}
+	}
+	if strings.Join(unexpected, ",") != "root.locaton,root.network_rules.bypas" {
+		t.Errorf("unexpected attribute findings %v", unexpected)
+	}
+}
+
+func TestWriteFindingsJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "findings.json")
+	err := WriteFindingsJSON(path, []ValidationFinding{
+		{ResourceType: "azurerm_storage_account", Path: "root.network_rules", Name: "bypass", Kind: FindingMissing},
+		{ResourceType: "azurerm_storage_account", Path: "root", Name: "location", Required: true, Kind: FindingMissing},
+		{ResourceType: "azurerm_linux_virtual_machine", Path: "root", Name: "depends_on", Kind: FindingInfo, Message: "prefer implicit references"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("decode findings JSON: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 findings, got %s", data)
+	}
+	if got[0]["path"] != "network_rules" || got[0]["severity"] != "optional" || got[0]["is_block"] != false {
+		t.Errorf("unexpected first finding %v", got[0])
+	}
+	if got[1]["severity"] != "required" || got[2]["severity"] != "info" || got[2]["message"] != "prefer implicit references" {
+		t.Errorf("unexpected severities %v", got)
+	}
+
+	if err := WriteFindingsJSON(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); strings.TrimSpace(string(data)) != "[]" {
+		t.Errorf("expected an empty array without findings, got %s", data)
+	}
+}
@@ -1,58 +1,175 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"math"
+	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 type ReportProcessor interface {
 	SetInputs(string)
-	ParseInputs()
+	ParseInputs() error
+	ReadFrom(r io.Reader) (int64, error)
+	ReadFile(path string) error
 	ValidateReports([]int) bool
+	ValidateWithDampener([]int) (bool, int)
 	ProcessReport()
+	Results() []ReportResult
+	Summary() (safe, unsafe, total int)
+	ProcessReportParallel(workers int) []ReportResult
 	PrintReport()
 }
 
+// ReportResult is the outcome of validating one report
+type ReportResult struct {
+	Report []int
+	Safe   bool
+	// FailIndex is the index i at which levels i and i+1 first break the
+	// rules, or -1 when the report is safe
+	FailIndex int
+	Reason    Reason
+	// DampenedIndex is the smallest index whose removal makes the report
+	// safe, or -1 when no removal was needed or none helps
+	DampenedIndex int
+}
+
+// Direction is the trend a safe report must follow
+type Direction int
+
+const (
+	DirectionAny Direction = iota
+	DirectionIncreasing
+	DirectionDecreasing
+)
+
+// Reason explains why a report is unsafe
+type Reason string
+
+const (
+	ReasonNone            Reason = ""
+	ReasonFlat            Reason = "flat"
+	ReasonTooBig          Reason = "too big"
+	ReasonDirectionChange Reason = "direction change"
+	ReasonWrongDirection  Reason = "wrong direction"
+)
+
 type ReportProcessorImpl struct {
 	RawInputs string
 	Reports   [][]int
+	// Dampener makes ProcessReport tolerate a single bad level per report
+	Dampener bool
+	// Direction restricts which trend counts as safe
+	Direction Direction
+
+	// mu guards Reports against ParseInputs or ReadFrom replacing them while
+	// results are computed
+	mu sync.RWMutex
 }
 
 func (rp *ReportProcessorImpl) SetInputs(inputs string) {
 	rp.RawInputs = inputs
 }
 
-func (rp *ReportProcessorImpl) ParseInputs() {
+// ParseInputs splits the raw input into reports, skipping blank lines. Every
+// field that is not an integer is reported with its line number, and Reports
+// is left unchanged if any are found.
+func (rp *ReportProcessorImpl) ParseInputs() error {
+	_, err := rp.ReadFrom(strings.NewReader(rp.RawInputs))
+	return err
+}
+
+// ReadFrom scans reports from r line by line, with the same rules as
+// ParseInputs. Lines may be of any length. It also returns the number of
+// bytes read, so ReportProcessorImpl satisfies io.ReaderFrom
+func (rp *ReportProcessorImpl) ReadFrom(r io.Reader) (int64, error) {
 	var reports [][]int
+	var errs []error
 
-	lines := strings.Split(rp.RawInputs, "\n")
-	for _, line := range lines {
-		s := strings.Fields(line)
+	cr := &countingReader{r: r}
+	scanner := bufio.NewScanner(cr)
+	scanner.Buffer(make([]byte, 0, 64*1024), math.MaxInt)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		s := strings.Fields(scanner.Text())
+		if len(s) == 0 {
+			continue
+		}
 		var tempslice []int
 		for _, v := range s {
 			d, err := strconv.Atoi(v)
 			if err != nil {
-				fmt.Println(err)
+				errs = append(errs, fmt.Errorf("line %d: invalid token %q", lineNo, v))
+				continue
 			}
 			tempslice = append(tempslice, d)
 		}
 		reports = append(reports, tempslice)
 	}
+	if err := scanner.Err(); err != nil {
+		return cr.n, fmt.Errorf("read reports: %w", err)
+	}
+	if len(errs) > 0 {
+		return cr.n, errors.Join(errs...)
+	}
+	rp.mu.Lock()
 	rp.Reports = reports
+	rp.mu.Unlock()
+	return cr.n, nil
+}
+
+// ReadFile reads reports from the file at path
+func (rp *ReportProcessorImpl) ReadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = rp.ReadFrom(f)
+	return err
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
 }
 
 func (rp *ReportProcessorImpl) ValidateReports(report []int) bool {
+	index, _ := firstViolation(report, rp.Direction)
+	return index == -1
+}
+
+// firstViolation returns the index i at which levels i and i+1 first break
+// a rule, and why, or -1 and ReasonNone if there is none
+func firstViolation(report []int, direction Direction) (int, Reason) {
 	isIncreasing := true
 	isDecreasing := true
 
 	for i := 0; i < len(report)-1; i++ {
 		diff := int(math.Abs(float64(report[i+1] - report[i])))
 
-		// Check if the difference is outside the valid range
-		if diff < 1 || diff > 3 {
-			return false
+		// Equal neighbours are neither increasing nor decreasing
+		if diff == 0 {
+			return i, ReasonFlat
+		}
+		if diff > 3 {
+			return i, ReasonTooBig
+		}
+		if direction == DirectionIncreasing && report[i] > report[i+1] ||
+			direction == DirectionDecreasing && report[i] < report[i+1] {
+			return i, ReasonWrongDirection
 		}
 
 		// Update monotonicity flags
@@ -65,27 +182,113 @@ func (rp *ReportProcessorImpl) ValidateReports(report []int) bool {
 
 		// If neither increasing nor decreasing, report is invalid
 		if !isIncreasing && !isDecreasing {
-			return false
+			return i, ReasonDirectionChange
 		}
 	}
 
 	// Report is valid if it has a consistent trend
-	return true
+	return -1, ReasonNone
+}
+
+// ValidateWithDampener reports whether report is safe as is or becomes safe
+// once a single level is removed, along with the smallest index removed or -1
+func (rp *ReportProcessorImpl) ValidateWithDampener(report []int) (bool, int) {
+	if rp.ValidateReports(report) {
+		return true, -1
+	}
+
+	dampened := make([]int, 0, len(report))
+	for skip := range report {
+		dampened = append(dampened[:0], report[:skip]...)
+		dampened = append(dampened, report[skip+1:]...)
+		if rp.ValidateReports(dampened) {
+			return true, skip
+		}
+	}
+	return false, -1
 }
 
 func (rp *ReportProcessorImpl) ProcessReport() {
-	for _, report := range rp.Reports {
-		if rp.ValidateReports(report) {
-			fmt.Println(report, "Safe")
+	rp.PrintReport()
+}
+
+// Results validates every report, using the dampener when enabled
+func (rp *ReportProcessorImpl) Results() []ReportResult {
+	reports := rp.snapshot()
+	results := make([]ReportResult, 0, len(reports))
+	for _, report := range reports {
+		results = append(results, rp.result(report))
+	}
+	return results
+}
+
+// ProcessReportParallel is Results with the reports spread over a pool of
+// workers goroutines; results keep the input order. The workers read a
+// snapshot, so reports parsed while it runs are not seen
+func (rp *ReportProcessorImpl) ProcessReportParallel(workers int) []ReportResult {
+	if workers < 1 {
+		workers = 1
+	}
+	reports := rp.snapshot()
+	results := make([]ReportResult, len(reports))
+	chunk := (len(reports) + workers - 1) / workers
+	var wg sync.WaitGroup
+	for start := 0; start < len(reports); start += chunk {
+		end := min(start+chunk, len(reports))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				results[i] = rp.result(reports[i])
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// snapshot returns a copy of Reports taken under the lock. ReadFrom replaces
+// the reports rather than changing them in place, so the copy is shallow
+func (rp *ReportProcessorImpl) snapshot() [][]int {
+	rp.mu.RLock()
+	defer rp.mu.RUnlock()
+	return slices.Clone(rp.Reports)
+}
+
+func (rp *ReportProcessorImpl) result(report []int) ReportResult {
+	result := ReportResult{Report: report, Safe: true, DampenedIndex: -1}
+	result.FailIndex, result.Reason = firstViolation(report, rp.Direction)
+	if result.FailIndex != -1 {
+		result.Safe = false
+		if rp.Dampener {
+			result.Safe, result.DampenedIndex = rp.ValidateWithDampener(report)
+		}
+		if result.Safe {
+			result.FailIndex, result.Reason = -1, ReasonNone
+		}
+	}
+	return result
+}
+
+// Summary counts the safe and unsafe reports, using the dampener when enabled
+func (rp *ReportProcessorImpl) Summary() (safe, unsafe, total int) {
+	for _, result := range rp.Results() {
+		if result.Safe {
+			safe++
 		} else {
-			fmt.Println(report, "Unsafe")
+			unsafe++
 		}
 	}
+	return safe, unsafe, safe + unsafe
 }
 
 func (rp *ReportProcessorImpl) PrintReport() {
-	for _, report := range rp.Reports {
-		fmt.Println(report)
+	for _, result := range rp.Results() {
+		if result.Safe {
+			fmt.Println(result.Report, "Safe")
+		} else {
+			fmt.Printf("%v Unsafe (%s at %d)\n", result.Report, result.Reason, result.FailIndex)
+		}
 	}
 }
 
@@ -100,6 +303,12 @@ func main() {
 1 3 6 7 9`
 
 	rp.SetInputs(input)
-	rp.ParseInputs()
+	if err := rp.ParseInputs(); err != nil {
+		fmt.Println(err)
+		return
+	}
 	rp.ProcessReport()
+
+	safe, _, total := rp.Summary()
+	fmt.Printf("\n%d of %d reports are safe\n", safe, total)
 }
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const sampleReports = `7 6 4 2 1
+1 2 7 8 9
+9 7 6 2 1
+1 3 2 4 5
+8 6 4 4 1
+1 3 6 7 9`
+
+func TestParseInputsInvalidToken(t *testing.T) {
+	rp := ReportProcessorImpl{}
+	rp.SetInputs("1 2 x 4")
+
+	err := rp.ParseInputs()
+	if err == nil {
+		t.Fatal("expected an error for a non-integer token")
+	}
+	if !strings.Contains(err.Error(), "line 1") || !strings.Contains(err.Error(), `"x"`) {
+		t.Errorf("expected error naming line 1 and \"x\", got %v", err)
+	}
+	if rp.Reports != nil {
+		t.Errorf("expected reports left unchanged, got %v", rp.Reports)
+	}
+}
+
+// parseReports returns a processor holding the parsed input.
+func parseReports(t *testing.T, input string) *ReportProcessorImpl {
+	t.Helper()
+	rp := &ReportProcessorImpl{}
+	rp.SetInputs(input)
+	if err := rp.ParseInputs(); err != nil {
+		t.Fatalf("parse inputs: %v", err)
+	}
+	return rp
+}
+
+func TestSummarySample(t *testing.T) {
+	rp := parseReports(t, sampleReports)
+
+	safe, unsafe, total := rp.Summary()
+	if safe != 2 || unsafe != 4 || total != 6 {
+		t.Errorf("expected 2 safe and 4 unsafe of 6, got %d, %d of %d", safe, unsafe, total)
+	}
+
+	rp.Dampener = true
+	safe, unsafe, total = rp.Summary()
+	if safe != 4 || unsafe != 2 || total != 6 {
+		t.Errorf("expected 4 safe and 2 unsafe of 6 with the dampener, got %d, %d of %d", safe, unsafe, total)
+	}
+}
+
+func TestResultsReason(t *testing.T) {
+	results := parseReports(t, "8 6 4 4 1").Results()
+
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	if got := results[0]; got.Safe || got.Reason != ReasonFlat || got.FailIndex != 2 {
+		t.Errorf("expected unsafe, flat at 2, got %+v", got)
+	}
+}
+
+func TestProcessReportParallelMatchesResults(t *testing.T) {
+	rp := parseReports(t, sampleReports)
+	rp.Dampener = true
+
+	serial := rp.Results()
+	parallel := rp.ProcessReportParallel(3)
+	if len(parallel) != len(serial) {
+		t.Fatalf("expected %d results, got %d", len(serial), len(parallel))
+	}
+	for i := range serial {
+		if parallel[i].Safe != serial[i].Safe || parallel[i].DampenedIndex != serial[i].DampenedIndex {
+			t.Errorf("report %d: parallel %+v, serial %+v", i, parallel[i], serial[i])
+		}
+	}
+}
+
+// benchmarkReports repeats the sample until it holds n reports.
+func benchmarkReports(b *testing.B, n int) *ReportProcessorImpl {
+	b.Helper()
+	lines := strings.Split(sampleReports, "\n")
+	input := make([]string, n)
+	for i := range input {
+		input[i] = lines[i%len(lines)]
+	}
+	rp := &ReportProcessorImpl{Dampener: true}
+	rp.SetInputs(strings.Join(input, "\n"))
+	if err := rp.ParseInputs(); err != nil {
+		b.Fatal(err)
+	}
+	return rp
+}
+
+func BenchmarkResults(b *testing.B) {
+	rp := benchmarkReports(b, 100000)
+	b.ResetTimer()
+	for range b.N {
+		rp.Results()
+	}
+}
+
+func BenchmarkProcessReportParallel(b *testing.B) {
+	rp := benchmarkReports(b, 100000)
+	b.ResetTimer()
+	for range b.N {
+		rp.ProcessReportParallel(8)
+	}
+}
+
+func TestDirection(t *testing.T) {
+	cases := []struct {
+		name      string
+		direction Direction
+		report    string
+		safe      bool
+		reason    Reason
+	}{
+		{"any accepts increasing", DirectionAny, "1 3 6 7 9", true, ReasonNone},
+		{"any accepts decreasing", DirectionAny, "7 6 4 2 1", true, ReasonNone},
+		{"increasing accepts increasing", DirectionIncreasing, "1 3 6 7 9", true, ReasonNone},
+		{"increasing rejects decreasing", DirectionIncreasing, "7 6 4 2 1", false, ReasonWrongDirection},
+		{"decreasing accepts decreasing", DirectionDecreasing, "7 6 4 2 1", true, ReasonNone},
+		{"decreasing rejects increasing", DirectionDecreasing, "1 3 6 7 9", false, ReasonWrongDirection},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rp := parseReports(t, tc.report)
+			rp.Direction = tc.direction
+
+			got := rp.Results()[0]
+			if got.Safe != tc.safe || got.Reason != tc.reason {
+				t.Errorf("expected safe=%t reason %q, got %+v", tc.safe, tc.reason, got)
+			}
+		})
+	}
+}
+
+func TestDampenedIndex(t *testing.T) {
+	rp := &ReportProcessorImpl{Dampener: true}
+
+	safe, index := rp.ValidateWithDampener([]int{1, 3, 2, 4, 5})
+	if !safe || index != 1 {
+		t.Errorf("expected 1 3 2 4 5 dampened at index 1, got safe=%t index %d", safe, index)
+	}
+	if _, index := rp.ValidateWithDampener([]int{1, 3, 6, 7, 9}); index != -1 {
+		t.Errorf("expected no removal for a safe report, got %d", index)
+	}
+
+	results := parseReports(t, "1 3 2 4 5\n1 2 7 8 9").Results()
+	if results[0].DampenedIndex != -1 {
+		t.Errorf("expected no dampening without the dampener, got %d", results[0].DampenedIndex)
+	}
+	rp = parseReports(t, "1 3 2 4 5\n1 2 7 8 9")
+	rp.Dampener = true
+	results = rp.Results()
+	if !results[0].Safe || results[0].DampenedIndex != 1 {
+		t.Errorf("expected first report dampened at 1, got %+v", results[0])
+	}
+	if results[1].Safe || results[1].DampenedIndex != -1 {
+		t.Errorf("expected second report unsafe with no dampened index, got %+v", results[1])
+	}
+}
+
+func TestProcessReportParallelDuringParse(t *testing.T) {
+	rp := parseReports(t, sampleReports)
+	rp.Dampener = true
+	want := rp.Results()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 100 {
+			rp.SetInputs("1 2 3\n9 9 9")
+			rp.ParseInputs()
+		}
+	}()
+	for range 100 {
+		got := rp.ProcessReportParallel(4)
+		if len(got) != len(want) && len(got) != 2 {
+			t.Fatalf("expected %d or 2 results, got %d", len(want), len(got))
+		}
+	}
+	<-done
+}
+
+func TestReadFromSkipsBlankLines(t *testing.T) {
+	input := "\n7 6 4 2 1\n\n   \n1 3 6 7 9\n\n\n"
+	rp := &ReportProcessorImpl{}
+
+	n, err := rp.ReadFrom(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(input)) {
+		t.Errorf("expected %d bytes read, got %d", len(input), n)
+	}
+	if len(rp.Reports) != 2 {
+		t.Fatalf("expected 2 reports, got %v", rp.Reports)
+	}
+	for i, report := range rp.Reports {
+		if len(report) == 0 {
+			t.Errorf("report %d is empty", i)
+		}
+	}
+}
+
+func TestReadFromLongLine(t *testing.T) {
+	levels := make([]string, 100000)
+	for i := range levels {
+		levels[i] = strconv.Itoa(i)
+	}
+	rp := &ReportProcessorImpl{}
+
+	if _, err := rp.ReadFrom(strings.NewReader(strings.Join(levels, " "))); err != nil {
+		t.Fatal(err)
+	}
+	if len(rp.Reports) != 1 || len(rp.Reports[0]) != len(levels) {
+		t.Errorf("expected one report of %d levels", len(levels))
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reports.txt")
+	if err := os.WriteFile(path, []byte(sampleReports+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rp := &ReportProcessorImpl{}
+	if err := rp.ReadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if len(rp.Reports) != 6 {
+		t.Errorf("expected 6 reports, got %d", len(rp.Reports))
+	}
+
+	err := rp.ReadFile(filepath.Join(t.TempDir(), "missing.txt"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a not-exist error, got %v", err)
+	}
+}
+
+func TestValidateWithDampener(t *testing.T) {
+	cases := []struct {
+		name   string
+		report []int
+		safe   bool
+		index  int
+	}{
+		{"single level", []int{5}, true, -1},
+		{"two equal levels", []int{1, 1}, true, 0},
+		{"two levels too far apart", []int{1, 9}, true, 0},
+		{"remove first", []int{9, 1, 2, 3}, true, 0},
+		{"remove last", []int{1, 2, 3, 9}, true, 3},
+		{"remove middle", []int{1, 3, 2, 4, 5}, true, 1},
+		{"no single removal", []int{1, 2, 7, 8, 9}, false, -1},
+	}
+	rp := &ReportProcessorImpl{Dampener: true}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			safe, index := rp.ValidateWithDampener(tc.report)
+			if safe != tc.safe || index != tc.index {
+				t.Errorf("expected %t, %d, got %t, %d", tc.safe, tc.index, safe, index)
+			}
+		})
+	}
+}
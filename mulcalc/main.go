@@ -1,51 +1,127 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"regexp"
 	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
 )
 
 const (
-	mulPattern = `mul\((\d+),(\d+)\)`
+	readChunkSize = 64 * 1024
+	// maxArgLen is the longest factor that fits int64, sign included
+	maxArgLen   = 20
+	opPattern   = `(%s)\((%[2]s),(%[2]s)\)|do\(\)|don't\(\)`
+	unsignedArg = `\d+`
+	signedArg   = `-?\d+`
+)
+
+var (
+	opNamePattern = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+	argsPattern   = regexp.MustCompile(`^-?\d*(,-?\d*)?$`)
 )
 
 // Custom errors
 var (
 	ErrEmptyInput = errors.New("input string is empty")
 	ErrNoMatches  = errors.New("no valid multiplication expressions found")
+	ErrOverflow   = errors.New("multiplication overflows int64")
+	ErrNilReader  = errors.New("input reader is nil")
+	ErrInvalidOp  = errors.New("invalid operator name")
 )
 
 // MulReconciler interface defines the contract for multiplication reconciliation
 type MulReconciler interface {
 	SetInput(input string) error
 	Process() error
+	ProcessContext(ctx context.Context) error
 	GetResults() []MultiplicationResult
-	GetTotal() int
+	GetTotal() int64
+	SetConditionalMode(enabled bool)
+	SetSignedMode(enabled bool)
+	SetReader(r io.Reader) error
+	RegisterOp(name string, fn func(x, y int64) (int64, error)) error
+	Reset()
 }
 
 // MultiplicationResult represents a single multiplication operation
 type MultiplicationResult struct {
+	Op       string
 	X        int
 	Y        int
-	Product  int
+	Product  int64
 	Original string
+	Enabled  bool
+	// StartIndex is the byte offset of Original in the input
+	StartIndex int
 }
 
 // MulReconcilerImpl implements the MulReconciler interface
 type MulReconcilerImpl struct {
 	input   string
 	results []MultiplicationResult
-	total   int
+	total   int64
 	regex   *regexp.Regexp
+	// ops maps operator names to their functions, in registration order
+	ops     map[string]func(x, y int64) (int64, error)
+	opNames []string
+	// conditional makes do() and don't() toggle the multiplications that follow
+	conditional bool
+	// signed accepts a leading minus sign on either factor
+	signed bool
+	reader io.Reader
+	// discardResults keeps only the running total while processing
+	discardResults bool
+	matched        int
+	enabled        bool
 }
 
 // NewMulReconciler creates a new instance of MulReconcilerImpl
 func NewMulReconciler() *MulReconcilerImpl {
-	return &MulReconcilerImpl{
-		regex: regexp.MustCompile(mulPattern),
+	mr := &MulReconcilerImpl{ops: make(map[string]func(x, y int64) (int64, error))}
+	mr.addOp("mul", checkedMul)
+	return mr
+}
+
+// RegisterOp adds or replaces an operator matched as name(x,y). fn works on
+// int64 like the built-in mul and should return ErrOverflow, or any other
+// error, when it cannot produce a result; the error stops Process.
+func (mr *MulReconcilerImpl) RegisterOp(name string, fn func(x, y int64) (int64, error)) error {
+	if !opNamePattern.MatchString(name) || name == "do" || name == "don't" {
+		return fmt.Errorf("%w: %q", ErrInvalidOp, name)
 	}
+	mr.addOp(name, fn)
+	return nil
+}
+
+func (mr *MulReconcilerImpl) addOp(name string, fn func(x, y int64) (int64, error)) {
+	if _, ok := mr.ops[name]; !ok {
+		mr.opNames = append(mr.opNames, name)
+	}
+	mr.ops[name] = fn
+	mr.compile()
+}
+
+// compile rebuilds the pattern from the registered operators and sign mode
+func (mr *MulReconcilerImpl) compile() {
+	quoted := make([]string, len(mr.opNames))
+	for i, n := range mr.opNames {
+		quoted[i] = regexp.QuoteMeta(n)
+	}
+	arg := unsignedArg
+	if mr.signed {
+		arg = signedArg
+	}
+	mr.regex = regexp.MustCompile(fmt.Sprintf(opPattern, strings.Join(quoted, "|"), arg))
 }
 
 // SetInput validates and sets the input string
@@ -54,54 +130,384 @@ func (mr *MulReconcilerImpl) SetInput(input string) error {
 		return ErrEmptyInput
 	}
 	mr.input = input
+	mr.reader = nil
 	return nil
 }
 
+// SetConditionalMode enables or disables do()/don't() handling
+func (mr *MulReconcilerImpl) SetConditionalMode(enabled bool) {
+	mr.conditional = enabled
+}
+
+// SetSignedMode enables or disables matching negative factors such as
+// mul(-3,4)
+func (mr *MulReconcilerImpl) SetSignedMode(enabled bool) {
+	if mr.signed != enabled {
+		mr.signed = enabled
+		mr.compile()
+	}
+}
+
+// SetReader streams the input from r on the next Process call, replacing any
+// string set by SetInput
+func (mr *MulReconcilerImpl) SetReader(r io.Reader) error {
+	if r == nil {
+		return ErrNilReader
+	}
+	mr.reader = r
+	mr.input = ""
+	return nil
+}
+
+// SetKeepResults controls whether Process collects every result; the total is
+// computed either way
+func (mr *MulReconcilerImpl) SetKeepResults(keep bool) {
+	mr.discardResults = !keep
+}
+
 // Process handles the multiplication expressions
 func (mr *MulReconcilerImpl) Process() error {
-	matches := mr.regex.FindAllStringSubmatch(mr.input, -1)
-	if len(matches) == 0 {
+	return mr.ProcessContext(context.Background())
+}
+
+// ProcessContext is Process that stops with ctx.Err() once ctx is done. The
+// input is matched in chunks and ctx is checked between them. Results and
+// total gathered before any error, cancellation included, are discarded.
+func (mr *MulReconcilerImpl) ProcessContext(ctx context.Context) error {
+	mr.startRun()
+
+	r := mr.reader
+	if r == nil {
+		r = strings.NewReader(mr.input)
+	}
+	if err := mr.processReader(ctx, r); err != nil {
+		mr.startRun()
+		return err
+	}
+
+	if mr.matched == 0 {
 		return ErrNoMatches
 	}
+	return nil
+}
 
-	mr.results = make([]MultiplicationResult, 0, len(matches))
-	mr.total = 0
+// ProcessParallel is Process with the matching spread over workers
+// goroutines. The input is split into chunks that end only at bytes no token
+// can contain, and matches are applied in input order, so results and total
+// equal those of Process. Input set with SetReader is read in full first.
+func (mr *MulReconcilerImpl) ProcessParallel(workers int) error {
+	return mr.ProcessParallelContext(context.Background(), workers)
+}
+
+// ProcessParallelContext is ProcessParallel that stops with ctx.Err() once
+// ctx is done. As with ProcessContext, results are discarded on any error.
+func (mr *MulReconcilerImpl) ProcessParallelContext(ctx context.Context, workers int) error {
+	mr.startRun()
+	if err := mr.processParallel(ctx, workers); err != nil {
+		mr.startRun()
+		return err
+	}
 
-	for _, match := range matches {
-		x, err := strconv.Atoi(match[1])
+	if mr.matched == 0 {
+		return ErrNoMatches
+	}
+	return nil
+}
+
+func (mr *MulReconcilerImpl) processParallel(ctx context.Context, workers int) error {
+	if workers < 1 {
+		workers = 1
+	}
+	if mr.reader != nil {
+		data, err := io.ReadAll(mr.reader)
 		if err != nil {
-			return fmt.Errorf("invalid first number: %w", err)
+			return fmt.Errorf("read input: %w", err)
+		}
+		mr.input = string(data)
+		mr.reader = nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	chunks := splitChunks(mr.input, workers)
+	locs := make([][][]int, len(chunks))
+	var wg sync.WaitGroup
+	for i, c := range chunks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ctx.Err() == nil {
+				locs[i] = mr.regex.FindAllStringSubmatchIndex(mr.input[c.start:c.end], -1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, c := range chunks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		text := mr.input[c.start:c.end]
+		for _, loc := range locs[i] {
+			if err := mr.apply(submatches(text, loc), c.start+loc[0]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// span is a byte range of the input
+type span struct {
+	start, end int
+}
+
+// splitChunks cuts s into at most n spans of roughly equal size, moving each
+// cut forward to a byte that cannot be part of any token
+func splitChunks(s string, n int) []span {
+	size := len(s) / n
+	var chunks []span
+	start := 0
+	for i := 1; i < n; i++ {
+		end := max(i*size, start)
+		for end < len(s) && !splitsTokens(s[end]) {
+			end++
+		}
+		if end >= len(s) {
+			break
+		}
+		if end > start {
+			chunks = append(chunks, span{start, end})
+			start = end
+		}
+	}
+	return append(chunks, span{start, len(s)})
+}
+
+// splitsTokens reports whether b can never occur inside a token. Operator
+// names are ASCII word characters, so any other ASCII byte qualifies.
+func splitsTokens(b byte) bool {
+	if b >= utf8.RuneSelf {
+		return false
+	}
+	switch {
+	case b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z', b >= '0' && b <= '9':
+		return false
+	}
+	return !strings.ContainsRune("_(),'-", rune(b))
+}
+
+// Reset clears the input, results and total so the reconciler can take a new
+// input, keeping the compiled pattern and registered operators. SetInput or
+// SetReader must be called again before the next Process.
+func (mr *MulReconcilerImpl) Reset() {
+	mr.input = ""
+	mr.reader = nil
+	mr.startRun()
+}
+
+// startRun clears the state of a previous Process call
+func (mr *MulReconcilerImpl) startRun() {
+	mr.results = nil
+	mr.total = 0
+	mr.matched = 0
+	mr.enabled = true
+}
+
+// processReader matches r chunk by chunk in a single reused buffer. Whatever
+// follows the last match is carried into the next chunk from the earliest
+// offset that could still begin a token; only the last maxTokenLen bytes are
+// considered, so a token that can never complete is dropped instead of
+// growing the buffer.
+func (mr *MulReconcilerImpl) processReader(ctx context.Context, r io.Reader) error {
+	maxToken := mr.maxTokenLen()
+	buf := make([]byte, 0, readChunkSize+maxToken)
+	// offset is the position of buf[0] in the full input
+	offset := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := r.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+
+		consumed := 0
+		for _, loc := range mr.regex.FindAllSubmatchIndex(buf, -1) {
+			if err := mr.apply(submatches(buf, loc), offset+loc[0]); err != nil {
+				return err
+			}
+			consumed = loc[1]
 		}
 
-		y, err := strconv.Atoi(match[2])
+		tail := buf[consumed:]
+		keep := len(tail)
+		for i := max(0, len(tail)-maxToken); i < len(tail); i++ {
+			if mr.incompleteToken(tail[i:]) {
+				keep = i
+				break
+			}
+		}
+		buf = buf[:copy(buf, tail[keep:])]
+		offset += consumed + keep
+
+		if err == io.EOF {
+			return nil
+		}
 		if err != nil {
-			return fmt.Errorf("invalid second number: %w", err)
+			return fmt.Errorf("read input: %w", err)
+		}
+	}
+}
+
+// maxTokenLen is the length of the longest token the pattern can match with
+// factors that fit int64
+func (mr *MulReconcilerImpl) maxTokenLen() int {
+	n := len("don't()")
+	for _, name := range mr.opNames {
+		n = max(n, len(name)+len("(,)")+2*maxArgLen)
+	}
+	return n
+}
+
+// incompleteToken reports whether s is the start of a token cut off by the
+// end of the buffer
+func (mr *MulReconcilerImpl) incompleteToken(s []byte) bool {
+	for _, control := range []string{"do()", "don't()"} {
+		if strings.HasPrefix(control, string(s)) {
+			return true
+		}
+	}
+	for _, name := range mr.opNames {
+		open := name + "("
+		if strings.HasPrefix(open, string(s)) {
+			return true
+		}
+		if bytes.HasPrefix(s, []byte(open)) && argsPattern.Match(s[len(open):]) {
+			return true
+		}
+	}
+	return false
+}
+
+// submatches returns the text of each group located by loc
+func submatches[T string | []byte](s T, loc []int) []string {
+	match := make([]string, len(loc)/2)
+	for i := range match {
+		if loc[2*i] >= 0 {
+			match[i] = string(s[loc[2*i]:loc[2*i+1]])
 		}
+	}
+	return match
+}
+
+// apply handles a single matched token found at byte offset start
+func (mr *MulReconcilerImpl) apply(match []string, start int) error {
+	switch match[0] {
+	case "do()":
+		mr.enabled = true
+		return nil
+	case "don't()":
+		mr.enabled = false
+		return nil
+	}
 
-		product := x * y
+	op := match[1]
+	x, err := strconv.Atoi(match[2])
+	if err != nil {
+		return fmt.Errorf("invalid first number: %w", err)
+	}
+
+	y, err := strconv.Atoi(match[3])
+	if err != nil {
+		return fmt.Errorf("invalid second number: %w", err)
+	}
+
+	product, err := mr.ops[op](int64(x), int64(y))
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, match[0])
+	}
+	active := mr.enabled || !mr.conditional
+	if active {
+		if product > 0 && mr.total > math.MaxInt64-product || product < 0 && mr.total < math.MinInt64-product {
+			return fmt.Errorf("%w: total exceeded at %s", ErrOverflow, match[0])
+		}
 		mr.total += product
+	}
+	mr.matched++
 
+	if !mr.discardResults {
 		mr.results = append(mr.results, MultiplicationResult{
-			X:        x,
-			Y:        y,
-			Product:  product,
-			Original: match[0],
+			Op:         op,
+			X:          x,
+			Y:          y,
+			Product:    product,
+			Original:   match[0],
+			Enabled:    active,
+			StartIndex: start,
 		})
 	}
-
 	return nil
 }
 
+// checkedMul multiplies two factors, reporting ErrOverflow
+func checkedMul(x, y int64) (int64, error) {
+	if x == 0 || y == 0 {
+		return 0, nil
+	}
+	product := x * y
+	if product/y != x || x == -1 && y == math.MinInt64 || y == -1 && x == math.MinInt64 {
+		return 0, ErrOverflow
+	}
+	return product, nil
+}
+
 // GetResults returns all multiplication results
 func (mr *MulReconcilerImpl) GetResults() []MultiplicationResult {
 	return mr.results
 }
 
 // GetTotal returns the sum of all multiplications
-func (mr *MulReconcilerImpl) GetTotal() int {
+func (mr *MulReconcilerImpl) GetTotal() int64 {
 	return mr.total
 }
 
+// resultRecord is the serialized form of a MultiplicationResult
+type resultRecord struct {
+	X        int    `json:"x"`
+	Y        int    `json:"y"`
+	Product  int64  `json:"product"`
+	Original string `json:"original"`
+}
+
+// GetResultsJSON encodes the results and the total as a JSON object
+func (mr *MulReconcilerImpl) GetResultsJSON() ([]byte, error) {
+	records := make([]resultRecord, len(mr.results))
+	for i, r := range mr.results {
+		records[i] = resultRecord{X: r.X, Y: r.Y, Product: r.Product, Original: r.Original}
+	}
+	return json.Marshal(struct {
+		Total   int64          `json:"total"`
+		Results []resultRecord `json:"results"`
+	}{mr.total, records})
+}
+
+// WriteCSV writes a header row followed by one row per result
+func (mr *MulReconcilerImpl) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"x", "y", "product", "original"}); err != nil {
+		return err
+	}
+	for _, r := range mr.results {
+		row := []string{strconv.Itoa(r.X), strconv.Itoa(r.Y), strconv.FormatInt(r.Product, 10), r.Original}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
 func main() {
 	mr := NewMulReconciler()
 	input := `xmul(2,4)%&mul[3,7]!@^do_not_mul(5,5)+mul(32,64]then(mul(11,8)mul(8,5))`
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// process runs input through a new reconciler.
+func process(t *testing.T, input string) *MulReconcilerImpl {
+	t.Helper()
+	mr := NewMulReconciler()
+	if err := mr.SetInput(input); err != nil {
+		t.Fatalf("set input: %v", err)
+	}
+	if err := mr.Process(); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	return mr
+}
+
+func TestProcessOverflow(t *testing.T) {
+	cases := map[string]string{
+		"product": "mul(9223372036854775807,2)",
+		"total":   "mul(4611686018427387904,1)mul(4611686018427387904,1)",
+	}
+	for name, input := range cases {
+		mr := NewMulReconciler()
+		if err := mr.SetInput(input); err != nil {
+			t.Fatal(err)
+		}
+		if err := mr.Process(); !errors.Is(err, ErrOverflow) {
+			t.Errorf("%s: expected ErrOverflow, got %v", name, err)
+		}
+	}
+
+	if mr := process(t, "mul(3037000499,3037000499)"); mr.GetTotal() != 3037000499*3037000499 {
+		t.Errorf("expected the largest square below the limit to fit, got %d", mr.GetTotal())
+	}
+}
+
+func TestResetBetweenInputs(t *testing.T) {
+	mr := NewMulReconciler()
+	for _, tc := range []struct {
+		input string
+		total int64
+		count int
+	}{
+		{"mul(2,4)mul(3,3)", 17, 2},
+		{"xmul(5,5)", 25, 1},
+		{"mul(1,1)mul(2,2)mul(3,3)", 14, 3},
+	} {
+		mr.Reset()
+		if err := mr.SetInput(tc.input); err != nil {
+			t.Fatal(err)
+		}
+		if err := mr.Process(); err != nil {
+			t.Fatalf("%s: %v", tc.input, err)
+		}
+		if mr.GetTotal() != tc.total || len(mr.GetResults()) != tc.count {
+			t.Errorf("%s: expected total %d from %d results, got %d from %d", tc.input, tc.total, tc.count, mr.GetTotal(), len(mr.GetResults()))
+		}
+	}
+
+	mr.Reset()
+	if mr.GetTotal() != 0 || mr.GetResults() != nil {
+		t.Errorf("expected Reset to clear results and total")
+	}
+	if err := mr.Process(); !errors.Is(err, ErrNoMatches) {
+		t.Errorf("expected the input cleared by Reset, got %v", err)
+	}
+}
+
+func TestSignedMode(t *testing.T) {
+	cases := []struct {
+		input   string
+		product int64
+	}{
+		{"mul(-2,-2)", 4},
+		{"mul(-5,3)", -15},
+	}
+	for _, tc := range cases {
+		mr := NewMulReconciler()
+		mr.SetSignedMode(true)
+		if err := mr.SetInput(tc.input); err != nil {
+			t.Fatal(err)
+		}
+		if err := mr.Process(); err != nil {
+			t.Fatalf("%s: %v", tc.input, err)
+		}
+		if results := mr.GetResults(); len(results) != 1 || results[0].Product != tc.product || mr.GetTotal() != tc.product {
+			t.Errorf("%s: expected %d, got %+v total %d", tc.input, tc.product, results, mr.GetTotal())
+		}
+	}
+
+	mr := NewMulReconciler()
+	if err := mr.SetInput("mul(-5,3)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.Process(); !errors.Is(err, ErrNoMatches) {
+		t.Errorf("expected negative factors ignored without signed mode, got %v", err)
+	}
+}
+
+func TestStreamTokenAcrossChunks(t *testing.T) {
+	input := strings.Repeat("x", readChunkSize-3) + "mul(12,34)" + strings.Repeat("y", readChunkSize) + "don't()mul(1,1)"
+	mr := NewMulReconciler()
+	mr.SetConditionalMode(true)
+	if err := mr.SetReader(strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.Process(); err != nil {
+		t.Fatal(err)
+	}
+	results := mr.GetResults()
+	if mr.GetTotal() != 408 || len(results) != 2 || results[0].StartIndex != readChunkSize-3 {
+		t.Errorf("expected the split token matched at %d, got total %d results %+v", readChunkSize-3, mr.GetTotal(), results)
+	}
+}
+
+// digitReader yields "mul(" followed by n digits and then tail.
+type digitReader struct {
+	n    int
+	tail io.Reader
+	head bool
+}
+
+func (d *digitReader) Read(p []byte) (int, error) {
+	if !d.head {
+		d.head = true
+		return copy(p, "mul("), nil
+	}
+	if d.n == 0 {
+		return d.tail.Read(p)
+	}
+	k := min(len(p), d.n)
+	for i := range k {
+		p[i] = '7'
+	}
+	d.n -= k
+	return k, nil
+}
+
+func TestStreamDropsEndlessToken(t *testing.T) {
+	mr := NewMulReconciler()
+	if err := mr.SetReader(&digitReader{n: 32 * readChunkSize, tail: strings.NewReader(" mul(2,3)")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.Process(); err != nil {
+		t.Fatal(err)
+	}
+	if mr.GetTotal() != 6 {
+		t.Errorf("expected only mul(2,3) counted, got %d", mr.GetTotal())
+	}
+}
+
+// failingReader returns data and then err.
+type failingReader struct {
+	data io.Reader
+	err  error
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	n, err := f.data.Read(p)
+	if err == io.EOF {
+		return n, f.err
+	}
+	return n, err
+}
+
+func TestProcessDiscardsResultsOnError(t *testing.T) {
+	readErr := errors.New("connection reset")
+	mr := NewMulReconciler()
+	if err := mr.SetReader(&failingReader{data: strings.NewReader("mul(2,3)mul(4,5)"), err: readErr}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.Process(); !errors.Is(err, readErr) {
+		t.Fatalf("expected the read error, got %v", err)
+	}
+	if mr.GetTotal() != 0 || mr.GetResults() != nil {
+		t.Errorf("expected partial results discarded, got total %d results %v", mr.GetTotal(), mr.GetResults())
+	}
+}
+
+func TestProcessParallelContextCanceled(t *testing.T) {
+	mr := process(t, "mul(2,3)")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := mr.ProcessParallelContext(ctx, 4); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if mr.GetTotal() != 0 || mr.GetResults() != nil {
+		t.Errorf("expected results discarded, got total %d results %v", mr.GetTotal(), mr.GetResults())
+	}
+}
+
+func TestRegisterOpOverflow(t *testing.T) {
+	mr := NewMulReconciler()
+	err := mr.RegisterOp("add", func(x, y int64) (int64, error) {
+		if y > 0 && x > math.MaxInt64-y {
+			return 0, ErrOverflow
+		}
+		return x + y, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mr.SetInput("add(2,3)mul(2,2)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.Process(); err != nil || mr.GetTotal() != 9 {
+		t.Fatalf("expected total 9, got %d (%v)", mr.GetTotal(), err)
+	}
+
+	if err := mr.SetInput("add(9223372036854775807,1)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.Process(); !errors.Is(err, ErrOverflow) {
+		t.Errorf("expected ErrOverflow from the custom op, got %v", err)
+	}
+
+	if err := mr.RegisterOp("do", nil); !errors.Is(err, ErrInvalidOp) {
+		t.Errorf("expected do to be rejected, got %v", err)
+	}
+}
+
+func TestProcessParallelMatchesProcess(t *testing.T) {
+	cases := []struct {
+		name        string
+		input       string
+		signed      bool
+		conditional bool
+	}{
+		{"tokens across cuts", strings.Repeat("mul(12,34) mul(5,6)%", 40), false, false},
+		{"tokens in noise", strings.Repeat("x%mul(7,8)]mul(1,2)then(mul(11,8)", 25), false, false},
+		{"signed factors", strings.Repeat("mul(-12,34) mul(5,-6)!-mul(-7,-8)", 30), true, false},
+		{"controls near cuts", strings.Repeat("mul(2,3) don't()%mul(4,5) do()!mul(6,7)", 30), false, true},
+		{"signed controls", strings.Repeat("don't() mul(-2,3)&do() mul(4,-5)x", 30), true, true},
+		{"short input", "do()mul(1,1)don't()", false, true},
+	}
+	for _, tc := range cases {
+		newReconciler := func() *MulReconcilerImpl {
+			mr := NewMulReconciler()
+			mr.SetSignedMode(tc.signed)
+			mr.SetConditionalMode(tc.conditional)
+			if err := mr.SetInput(tc.input); err != nil {
+				t.Fatal(err)
+			}
+			return mr
+		}
+		serial := newReconciler()
+		if err := serial.Process(); err != nil {
+			t.Fatalf("%s: process: %v", tc.name, err)
+		}
+
+		if len(splitChunks(tc.input, 8)) < 2 && len(tc.input) > 100 {
+			t.Fatalf("%s: input is never split", tc.name)
+		}
+		for workers := 1; workers <= 8; workers++ {
+			parallel := newReconciler()
+			if err := parallel.ProcessParallel(workers); err != nil {
+				t.Fatalf("%s with %d workers: %v", tc.name, workers, err)
+			}
+			if parallel.GetTotal() != serial.GetTotal() {
+				t.Errorf("%s with %d workers: total %d, serial %d", tc.name, workers, parallel.GetTotal(), serial.GetTotal())
+			}
+			if !slices.Equal(parallel.GetResults(), serial.GetResults()) {
+				t.Errorf("%s with %d workers: results differ from serial:\n%+v\n%+v", tc.name, workers, parallel.GetResults(), serial.GetResults())
+			}
+		}
+	}
+}
+
+func TestGetResultsJSON(t *testing.T) {
+	mr := process(t, "mul(2,4)xmul(11,8)")
+
+	data, err := mr.GetResultsJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Total   int64 `json:"total"`
+		Results []struct {
+			X        int    `json:"x"`
+			Y        int    `json:"y"`
+			Product  int64  `json:"product"`
+			Original string `json:"original"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("unmarshal %s: %v", data, err)
+	}
+	if got.Total != 96 || len(got.Results) != 2 {
+		t.Fatalf("expected total 96 from 2 results, got %s", data)
+	}
+	if r := got.Results[1]; r.X != 11 || r.Y != 8 || r.Product != 88 || r.Original != "mul(11,8)" {
+		t.Errorf("unexpected second result %+v", r)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	mr := process(t, "mul(2,4)mul(3,5)")
+	mr.results = append(mr.results, MultiplicationResult{X: 1, Y: 1, Product: 1, Original: `say "hi", then`})
+
+	var buf strings.Builder
+	if err := mr.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "x,y,product,original\n" +
+		"2,4,8,\"mul(2,4)\"\n" +
+		"3,5,15,\"mul(3,5)\"\n" +
+		"1,1,1,\"say \"\"hi\"\", then\"\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	rows, err := csv.NewReader(strings.NewReader(buf.String())).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 || rows[3][3] != `say "hi", then` {
+		t.Errorf("expected the quoted original to round-trip, got %q", rows)
+	}
+}
+
+func TestConditionalMode(t *testing.T) {
+	cases := []struct {
+		name        string
+		input       string
+		conditional bool
+		total       int64
+		enabled     []bool
+	}{
+		{"default enabled", "mul(2,3)mul(4,5)", true, 26, []bool{true, true}},
+		{"leading don't", "don't()mul(2,3)mul(4,5)", true, 0, []bool{false, false}},
+		{"do re-enables", "don't()mul(2,3)do()mul(4,5)", true, 20, []bool{false, true}},
+		{"toggles", "mul(1,1)don't()mul(2,3)do()mul(4,5)don't()mul(6,7)", true, 21, []bool{true, false, true, false}},
+		{"ignored by default", "don't()mul(2,3)do()mul(4,5)don't()mul(6,7)", false, 68, []bool{true, true, true}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mr := NewMulReconciler()
+			mr.SetConditionalMode(tc.conditional)
+			if err := mr.SetInput(tc.input); err != nil {
+				t.Fatal(err)
+			}
+			if err := mr.Process(); err != nil {
+				t.Fatal(err)
+			}
+
+			if mr.GetTotal() != tc.total {
+				t.Errorf("expected total %d, got %d", tc.total, mr.GetTotal())
+			}
+			var enabled []bool
+			for _, r := range mr.GetResults() {
+				enabled = append(enabled, r.Enabled)
+			}
+			if !slices.Equal(enabled, tc.enabled) {
+				t.Errorf("expected enabled flags %v, got %v", tc.enabled, enabled)
+			}
+		})
+	}
+}
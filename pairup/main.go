@@ -1,61 +1,273 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
-	"math"
-	"sort"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
 )
 
-type ListReconciler interface {
-	SetInputs(left, right []int)
+// Number is the set of numeric types the lists can hold
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 |
+		~float32 | ~float64
+}
+
+// GenericListReconciler is ListReconciler for lists of any numeric type
+type GenericListReconciler[T Number] interface {
+	SetInputs(left, right []T)
+	ParseColumns(r io.Reader) error
 	ValidateInputs() error
 	SortLists()
 	ComputeDifferences()
-	PrintDifferences()
+	ComputeSimilarity() T
+	Pairs() ([]PairResult[T], error)
+	DiffStats() (Stats[T], error)
+	DisplayResults()
+}
+
+// ListReconciler is the int reconciler interface kept for existing callers
+type ListReconciler = GenericListReconciler[int]
+
+// ErrNotComputed is returned when results are read before ComputeDifferences
+var ErrNotComputed = errors.New("differences have not been computed")
+
+// ErrEmptyLists is returned by ValidateInputs when both lists are empty and
+// AllowEmpty is not set
+var ErrEmptyLists = errors.New("error: left and right lists are empty")
+
+// PairResult is one aligned pair and the absolute difference between them
+type PairResult[T Number] struct {
+	Left  T
+	Right T
+	Diff  T
 }
 
-type ListReconsilerImpl struct {
-	LeftList  []int
-	RightList []int
-	Diffs     []int
-	TotalDiff int
+// Stats summarizes the computed differences
+type Stats[T Number] struct {
+	Mean   float64
+	Median float64
+	Min    T
+	Max    T
 }
 
-func (lr *ListReconsilerImpl) SetInputs(left, right []int) {
+// ListReconsiler reconciles two lists of any numeric type
+type ListReconsiler[T Number] struct {
+	LeftList  []T
+	RightList []T
+	Diffs     []T
+	TotalDiff T
+	// SimilarityScore is the sum of each left value times its count in the
+	// right list
+	SimilarityScore T
+	// AllowEmpty accepts two empty lists instead of returning ErrEmptyLists
+	AllowEmpty bool
+}
+
+// ListReconsilerImpl is the int reconciler kept for existing callers
+type ListReconsilerImpl = ListReconsiler[int]
+
+func (lr *ListReconsiler[T]) SetInputs(left, right []T) {
 	lr.LeftList = left
 	lr.RightList = right
 }
 
-func (lr *ListReconsilerImpl) SortLists() {
-	sort.Ints(lr.LeftList)
-	sort.Ints(lr.RightList)
+// ParseColumns reads lines of two whitespace separated numbers and appends
+// them to the left and right lists. Blank lines are skipped.
+func (lr *ListReconsiler[T]) ParseColumns(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return fmt.Errorf("line %d: expected two numbers, got %d", line, len(fields))
+		}
+
+		left, err := parseNumber[T](fields[0])
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		right, err := parseNumber[T](fields[1])
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		lr.LeftList = append(lr.LeftList, left)
+		lr.RightList = append(lr.RightList, right)
+	}
+	return scanner.Err()
+}
+
+// parseNumber parses s into T. Named types such as `type ID int` are not
+// matched by the type switch and are rejected.
+func parseNumber[T Number](s string) (T, error) {
+	var zero T
+	switch any(zero).(type) {
+	case float32:
+		return parseFloat[T](s, 32)
+	case float64:
+		return parseFloat[T](s, 64)
+	case uint:
+		return parseUint[T](s, strconv.IntSize)
+	case uint8:
+		return parseUint[T](s, 8)
+	case uint16:
+		return parseUint[T](s, 16)
+	case uint32:
+		return parseUint[T](s, 32)
+	case uint64:
+		return parseUint[T](s, 64)
+	case int:
+		return parseInt[T](s, strconv.IntSize)
+	case int8:
+		return parseInt[T](s, 8)
+	case int16:
+		return parseInt[T](s, 16)
+	case int32:
+		return parseInt[T](s, 32)
+	case int64:
+		return parseInt[T](s, 64)
+	}
+	return zero, fmt.Errorf("cannot parse numbers of type %T", zero)
+}
+
+func parseFloat[T Number](s string, bitSize int) (T, error) {
+	f, err := strconv.ParseFloat(s, bitSize)
+	if err != nil {
+		return 0, err
+	}
+	return T(f), nil
+}
+
+func parseUint[T Number](s string, bitSize int) (T, error) {
+	u, err := strconv.ParseUint(s, 10, bitSize)
+	if err != nil {
+		return 0, err
+	}
+	return T(u), nil
+}
+
+func parseInt[T Number](s string, bitSize int) (T, error) {
+	i, err := strconv.ParseInt(s, 10, bitSize)
+	if err != nil {
+		return 0, err
+	}
+	return T(i), nil
+}
+
+func (lr *ListReconsiler[T]) SortLists() {
+	slices.Sort(lr.LeftList)
+	slices.Sort(lr.RightList)
 }
 
-func (lr *ListReconsilerImpl) ValidateInputs() error {
+func (lr *ListReconsiler[T]) ValidateInputs() error {
 	if len(lr.LeftList) != len(lr.RightList) {
 		return fmt.Errorf("error: left and right lists must have the same length")
 	}
+	if len(lr.LeftList) == 0 && !lr.AllowEmpty {
+		return ErrEmptyLists
+	}
 	return nil
 }
 
-func (lr *ListReconsilerImpl) ComputeDifferences() {
-	lr.Diffs = make([]int, len(lr.LeftList))
-	total := 0
+func (lr *ListReconsiler[T]) ComputeDifferences() {
+	lr.Diffs = make([]T, len(lr.LeftList))
+	var total T
 	for i := 0; i < len(lr.LeftList); i++ {
-		diff := int(math.Abs(float64(lr.LeftList[i] - lr.RightList[i])))
+		diff := absDiff(lr.LeftList[i], lr.RightList[i])
 		lr.Diffs[i] = diff
 		total += diff
 	}
 	lr.TotalDiff = total
 }
 
-func (lr *ListReconsilerImpl) DisplayResults() {
+// Pairs returns each aligned pair with its difference. Call it after
+// SortLists and ComputeDifferences.
+func (lr *ListReconsiler[T]) Pairs() ([]PairResult[T], error) {
+	if lr.Diffs == nil || len(lr.Diffs) != len(lr.LeftList) {
+		return nil, ErrNotComputed
+	}
+
+	pairs := make([]PairResult[T], len(lr.Diffs))
+	for i, diff := range lr.Diffs {
+		pairs[i] = PairResult[T]{Left: lr.LeftList[i], Right: lr.RightList[i], Diff: diff}
+	}
+	return pairs, nil
+}
+
+// DiffStats returns the mean, median, min and max of the computed
+// differences. The median of an even number of differences is the average of
+// the two middle values.
+func (lr *ListReconsiler[T]) DiffStats() (Stats[T], error) {
+	if lr.Diffs == nil || len(lr.Diffs) != len(lr.LeftList) {
+		return Stats[T]{}, ErrNotComputed
+	}
+	if len(lr.Diffs) == 0 {
+		return Stats[T]{}, nil
+	}
+
+	sorted := slices.Clone(lr.Diffs)
+	slices.Sort(sorted)
+
+	var sum float64
+	for _, d := range sorted {
+		sum += float64(d)
+	}
+
+	n := len(sorted)
+	median := float64(sorted[n/2])
+	if n%2 == 0 {
+		median = (float64(sorted[n/2-1]) + float64(sorted[n/2])) / 2
+	}
+
+	return Stats[T]{
+		Mean:   sum / float64(n),
+		Median: median,
+		Min:    sorted[0],
+		Max:    sorted[n-1],
+	}, nil
+}
+
+// absDiff returns |a - b| without underflowing unsigned types
+func absDiff[T Number](a, b T) T {
+	if a < b {
+		return b - a
+	}
+	return a - b
+}
+
+// ComputeSimilarity multiplies each left value by the number of times it
+// appears in the right list and sums the products. The lists need not be
+// sorted.
+func (lr *ListReconsiler[T]) ComputeSimilarity() T {
+	counts := make(map[T]int, len(lr.RightList))
+	for _, v := range lr.RightList {
+		counts[v]++
+	}
+
+	var score T
+	for _, v := range lr.LeftList {
+		score += v * T(counts[v])
+	}
+	lr.SimilarityScore = score
+	return score
+}
+
+func (lr *ListReconsiler[T]) DisplayResults() {
 	for i := 0; i < len(lr.LeftList); i++ {
-		fmt.Printf("%d %d %d\n", lr.LeftList[i], lr.RightList[i], lr.Diffs[i])
+		fmt.Printf("%v %v %v\n", lr.LeftList[i], lr.RightList[i], lr.Diffs[i])
 	}
-	fmt.Printf("Total: %d\n", lr.TotalDiff)
+	fmt.Printf("Total: %v\n", lr.TotalDiff)
+	fmt.Printf("Similarity: %v\n", lr.SimilarityScore)
 }
 
+var _ ListReconciler = (*ListReconsilerImpl)(nil)
+
 func main() {
 	lr := ListReconsilerImpl{}
 	lr.SetInputs([]int{3, 4, 2, 1, 3, 3}, []int{4, 3, 5, 3, 9, 7})
@@ -67,5 +279,6 @@ func main() {
 
 	lr.SortLists()
 	lr.ComputeDifferences()
+	lr.ComputeSimilarity()
 	lr.DisplayResults()
 }
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// sample returns the puzzle's example lists.
+func sample() ([]int, []int) {
+	return []int{3, 4, 2, 1, 3, 3}, []int{4, 3, 5, 3, 9, 3}
+}
+
+func TestComputeSimilaritySample(t *testing.T) {
+	lr := ListReconsilerImpl{}
+	lr.SetInputs(sample())
+
+	if got := lr.ComputeSimilarity(); got != 31 || lr.SimilarityScore != 31 {
+		t.Errorf("expected similarity 31, got %d (field %d)", got, lr.SimilarityScore)
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	input := `3   4
+4 3
+
+2	5
+1 3
+`
+	lr := ListReconsilerImpl{}
+	if err := lr.ParseColumns(strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(lr.LeftList, []int{3, 4, 2, 1}) || !slices.Equal(lr.RightList, []int{4, 3, 5, 3}) {
+		t.Errorf("unexpected lists %v and %v", lr.LeftList, lr.RightList)
+	}
+
+	for input, want := range map[string]string{
+		"1 2\n3 4 5\n": "line 2: expected two numbers, got 3",
+		"1 2\n7\n":     "line 2: expected two numbers, got 1",
+		"1 x\n":        "line 1:",
+	} {
+		lr := ListReconsilerImpl{}
+		err := lr.ParseColumns(strings.NewReader(input))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: expected error containing %q, got %v", input, want, err)
+		}
+	}
+}
+
+func TestDiffStats(t *testing.T) {
+	lr := ListReconsilerImpl{}
+	lr.SetInputs(sample())
+	if _, err := lr.DiffStats(); !errors.Is(err, ErrNotComputed) {
+		t.Fatalf("expected ErrNotComputed before ComputeDifferences, got %v", err)
+	}
+
+	lr.SortLists()
+	lr.ComputeDifferences()
+	stats, err := lr.DiffStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sorted differences are 0 1 1 2 2 5.
+	want := Stats[int]{Mean: 11.0 / 6, Median: 1.5, Min: 0, Max: 5}
+	if stats != want {
+		t.Errorf("expected %+v, got %+v", want, stats)
+	}
+
+	odd := ListReconsilerImpl{}
+	odd.SetInputs([]int{1, 2, 3}, []int{4, 2, 10})
+	odd.ComputeDifferences()
+	if stats, _ := odd.DiffStats(); stats.Median != 3 {
+		t.Errorf("expected the middle difference as median, got %v", stats.Median)
+	}
+}
+
+func TestValidateInputs(t *testing.T) {
+	cases := []struct {
+		name        string
+		left, right []int
+		allowEmpty  bool
+		want        error
+	}{
+		{"empty", nil, nil, false, ErrEmptyLists},
+		{"empty allowed", nil, []int{}, true, nil},
+		{"mismatched", []int{1, 2}, []int{1}, false, errors.New("error: left and right lists must have the same length")},
+		{"equal non-empty", []int{1, 2}, []int{3, 4}, false, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			lr := ListReconsilerImpl{AllowEmpty: tc.allowEmpty}
+			lr.SetInputs(tc.left, tc.right)
+
+			err := lr.ValidateInputs()
+			switch {
+			case tc.want == nil && err != nil:
+				t.Errorf("expected no error, got %v", err)
+			case tc.want == ErrEmptyLists && !errors.Is(err, ErrEmptyLists):
+				t.Errorf("expected ErrEmptyLists, got %v", err)
+			case tc.want != nil && (err == nil || err.Error() != tc.want.Error()):
+				t.Errorf("expected %v, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	if f, err := parseNumber[float64]("1.5"); err != nil || f != 1.5 {
+		t.Errorf("float64: got %v, %v", f, err)
+	}
+	if u, err := parseNumber[uint8]("255"); err != nil || u != 255 {
+		t.Errorf("uint8: got %v, %v", u, err)
+	}
+	if _, err := parseNumber[uint8]("256"); err == nil {
+		t.Error("uint8: expected out of range error")
+	}
+	if i, err := parseNumber[int16]("-7"); err != nil || i != -7 {
+		t.Errorf("int16: got %v, %v", i, err)
+	}
+	type id int
+	if _, err := parseNumber[id]("1"); err == nil {
+		t.Error("named type: expected error")
+	}
+}